@@ -0,0 +1,131 @@
+package main
+
+import (
+	"github.com/go-openapi/spec"
+	"github.com/jackmanlabs/errors"
+	"go/types"
+	"gopkg.in/yaml.v3"
+	"os"
+)
+
+/*
+The configuration file is a YAML (or JSON) document. For now, its only section
+maps fully qualified Go types to the schema that describes them on the wire,
+for types that would otherwise be described poorly, usually because they come
+from a third party and marshal themselves:
+
+	types:
+	  github.com/shopspring/decimal.Decimal:
+	    type: string
+	    pattern: '^-?[0-9]+(\.[0-9]+)?$'
+	  example.com/lib/null.String:
+	    type: string
+	    nullable: true
+
+Mapped types are described inline wherever they're used, like primitive types;
+no model is generated for them. The configuration file adds to (and may
+override) the built-in mappings below.
+*/
+type Config struct {
+	Types map[string]TypeMapping `yaml:"types"`
+}
+
+type TypeMapping struct {
+	Type     string `yaml:"type"` // A Swagger type. If empty, any value is accepted.
+	Format   string `yaml:"format"`
+	Pattern  string `yaml:"pattern"`
+	Nullable bool   `yaml:"nullable"`
+}
+
+// map[package path.type name]mapping
+var typeMappings map[string]TypeMapping = map[string]TypeMapping{
+	"time.Time":                             {Type: "string", Format: "date-time"},
+	"time.Duration":                         {Type: "integer", Format: "int64"}, // Nanoseconds.
+	"encoding/json.RawMessage":              {},
+	"encoding/json/jsontext.Value":          {}, // What json.RawMessage is an alias of, in later versions of Go.
+	"encoding/json.Number":                  {Type: "number"},
+	"math/big.Int":                          {Type: "integer"},
+	"math/big.Float":                        {Type: "string"}, // Written by MarshalText.
+	"net.IP":                                {Type: "string"},
+	"github.com/google/uuid.UUID":           {Type: "string", Format: "uuid"},
+	"github.com/gofrs/uuid.UUID":            {Type: "string", Format: "uuid"},
+	"github.com/satori/go.uuid.UUID":        {Type: "string", Format: "uuid"},
+	"github.com/shopspring/decimal.Decimal": {Type: "string", Pattern: `^-?[0-9]+(\.[0-9]+)?$`},
+	"gopkg.in/guregu/null.v4.String":        {Type: "string", Nullable: true},
+	"gopkg.in/guregu/null.v4.Int":           {Type: "integer", Format: "int64", Nullable: true},
+	"gopkg.in/guregu/null.v4.Float":         {Type: "number", Format: "double", Nullable: true},
+	"gopkg.in/guregu/null.v4.Bool":          {Type: "boolean", Nullable: true},
+	"gopkg.in/guregu/null.v4.Time":          {Type: "string", Format: "date-time", Nullable: true},
+}
+
+func loadConfig(path string) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Stack(err)
+	}
+	defer f.Close()
+
+	var config Config
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(&config)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	for goType, mapping := range config.Types {
+		switch mapping.Type {
+		case "", "string", "integer", "number", "boolean", "object":
+		default:
+			return errors.Newf("Unsupported type (%s) for mapped type: %s", mapping.Type, goType)
+		}
+
+		typeMappings[goType] = mapping
+	}
+
+	return nil
+}
+
+// Returns the mapping for the type, if there is one.
+func mappedType(obj *types.TypeName) (TypeMapping, bool) {
+
+	if obj == nil || obj.Pkg() == nil {
+		return TypeMapping{}, false
+	}
+
+	mapping, ok := typeMappings[obj.Pkg().Path()+"."+obj.Name()]
+	return mapping, ok
+}
+
+/*
+Adds the properties of the mapping that aren't covered by the type and format.
+
+Swagger 2.0 has no notion of null, so the 'x-nullable' extension understood by
+several code generators is used instead. OpenAPI 3.0 has a 'nullable' keyword,
+while OpenAPI 3.1 adds 'null' to the types.
+*/
+func (this TypeMapping) apply(schema *spec.Schema) *spec.Schema {
+
+	if this.Pattern != "" {
+		schema.WithPattern(this.Pattern)
+	}
+
+	if !this.Nullable {
+		return schema
+	}
+
+	switch *openapi {
+	case "2.0":
+		schema.AddExtension("x-nullable", true)
+	case "3.0":
+		withExtraProp(schema, "nullable", true)
+	default:
+		if this.Type != "" {
+			schema = nullableSchema(schema)
+		}
+	}
+
+	return schema
+}
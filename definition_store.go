@@ -1,6 +1,8 @@
 package main
 
 import (
+	"go/types"
+	"sort"
 	"strings"
 )
 
@@ -17,32 +19,180 @@ type DefinitionStore map[string]*DefinitionIntermediate
 
 func (this DefinitionStore) Add(intermediate *DefinitionIntermediate) {
 
-	_, ok := this[intermediate.CanonicalName()]
+	key := storeKey(intermediate.Object, intermediate.TypeArgs)
+
+	_, ok := this[key]
 	if ok {
-		//log.Print("duplicate detected: " + intermediate.CanonicalName())
+		//log.Print("duplicate detected: " + key)
 		//jlog.Log(this)
 	}
 
-	this[intermediate.CanonicalName()] = intermediate
+	this[key] = intermediate
+}
+
+// The type arguments are nil, unless the type is an instantiation of a generic
+// type.
+func (this DefinitionStore) ExistsDefinition(obj *types.TypeName, typeArgs []types.Type) (*DefinitionIntermediate, bool) {
+
+	def, ok := this[storeKey(obj, typeArgs)]
+	if !ok || def.Object != obj || !identicalTypeArguments(def.TypeArgs, typeArgs) {
+		return nil, false
+	}
+
+	return def, true
+}
+
+/*
+The definitions of generic types are stored by their fully qualified type
+arguments, since their names leave out the packages of the type arguments (as
+well as pointers), and may well be the same for different instantiations:
+
+	example.com/proj/model.Page[example.com/proj/model.Base]
+	example.com/proj/model.Page[example.com/proj/om.Base]
+*/
+func storeKey(obj *types.TypeName, typeArgs []types.Type) string {
+
+	if obj.Pkg() == nil {
+		// Predeclared types, such as 'error', which are never defined.
+		return obj.Name()
+	}
+
+	key := obj.Pkg().Path() + "." + obj.Name()
+	if len(typeArgs) == 0 {
+		return key
+	}
+
+	argNames := make([]string, 0)
+	for _, typeArg := range typeArgs {
+		argNames = append(argNames, types.TypeString(typeArg, nil))
+	}
+
+	return key + "[" + strings.Join(argNames, ", ") + "]"
+}
+
+// Returns the keys of the definitions in a stable order, which decides which of
+// the models that share a name is documented.
+func (this DefinitionStore) Keys() []string {
+
+	keys := make([]string, 0)
+	for key := range this {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
-func (this DefinitionStore) ExistsDefinition(referringPackage, typeName string) (*DefinitionIntermediate, bool) {
+/*
+The 'partial' and 'simple' naming conventions drop some or all of the package
+path, so two types may end up with the same Swagger name. Each collision is
+reported. When disambiguating, the colliding types (and only those) are named
+after the shortest suffix of their package path that tells them apart:
+
+	example.com/billing/model.Account  ->  billing.model.Account
+	example.com/crm/model.Account      ->  crm.model.Account
+
+Names chosen with the @SwaggerName tag are never changed, so a collision between
+two of them can't be disambiguated.
+*/
+func (this DefinitionStore) ResolveCollisions(disambiguate bool) {
+
+	// Visit the definitions in a stable order, for stable diagnostics.
+	canonicalNames := this.Keys()
 
-	pkgInfo := pkgInfos[referringPackage]
-	importPaths := possibleImportPaths(pkgInfo, typeName)
+	// map[swaggerName]definitions
+	collisions := make(map[string][]*DefinitionIntermediate)
+	swaggerNames := make([]string, 0)
+	for _, canonicalName := range canonicalNames {
+		definition := this[canonicalName]
+		swaggerName := definition.SwaggerName()
+		if _, ok := collisions[swaggerName]; !ok {
+			swaggerNames = append(swaggerNames, swaggerName)
+		}
+		collisions[swaggerName] = append(collisions[swaggerName], definition)
+	}
+
+	for _, swaggerName := range swaggerNames {
+		definitions := collisions[swaggerName]
+		if len(definitions) < 2 {
+			continue
+		}
+
+		customNames := 0
+		for _, definition := range definitions {
+			if definition.CustomName != "" {
+				customNames++
+			}
+		}
 
-	idx := strings.Index(typeName, ".")
-	if idx != -1 {
-		typeName = typeName[idx+1:]
+		canDisambiguate := disambiguate && customNames < 2
+
+		for _, definition := range definitions[1:] {
+			first := definitions[0]
+			if canDisambiguate {
+				reportInfo(definition.Object.Pos(), "name-collision", "Model name (%s) is shared with %s (at %s), and will be disambiguated.",
+					swaggerName, storeKey(first.Object, first.TypeArgs), formatPos(first.Object.Pos()))
+			} else {
+				reportProblem(definition.Object.Pos(), "name-collision", "Model name (%s) is shared with %s (at %s); only one of them will be documented.",
+					swaggerName, storeKey(first.Object, first.TypeArgs), formatPos(first.Object.Pos()))
+			}
+		}
+
+		if canDisambiguate {
+			disambiguateNames(definitions)
+		}
 	}
+}
+
+func disambiguateNames(definitions []*DefinitionIntermediate) {
 
-	for _, importPath := range importPaths {
-		for _, def := range this {
-			if def.PackagePath == importPath && def.Name == typeName {
-				return def, true
+	// How many segments of each package path are needed to be unique? The
+	// instantiations of a generic type share its package path, so they're told
+	// apart by the package paths of their type arguments.
+	for _, definition := range definitions {
+		if definition.CustomName != "" {
+			continue
+		}
+
+		segments := len(strings.Split(definition.PackagePath, "/"))
+		if depth := typeArgumentDepth(definition.TypeArgs); depth > segments {
+			segments = depth
+		}
+
+		for n := 1; n <= segments; n++ {
+			name := disambiguatedName(definition, n)
+
+			unique := true
+			for _, other := range definitions {
+				if other != definition && disambiguatedName(other, n) == name {
+					unique = false
+					break
+				}
+			}
+
+			if unique || n == segments {
+				definition.UniqueName = name
+				break
 			}
 		}
 	}
+}
+
+func disambiguatedName(definition *DefinitionIntermediate, n int) string {
+
+	name := definition.Name
+	if len(definition.TypeArgs) > 0 {
+		name = qualifiedInstanceName(definition.Object.Name(), definition.TypeArgs, n)
+	}
 
-	return nil, false
+	return strings.Replace(pathSuffix(definition.PackagePath, n), "/", ".", -1) + "." + name
+}
+
+// Returns the last n segments of a package path.
+func pathSuffix(pkgPath string, n int) string {
+	segments := strings.Split(pkgPath, "/")
+	if n > len(segments) {
+		n = len(segments)
+	}
+	return strings.Join(segments[len(segments)-n:], "/")
 }
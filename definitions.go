@@ -2,19 +2,36 @@ package main
 
 import (
 	"github.com/jackmanlabs/errors"
-	"strings"
+	"go/token"
 )
 
 func deriveDefinitionsFromOperations(operationIntermediates []OperationIntermediate) error {
 	for _, operationIntermediate := range operationIntermediates {
 		for _, responseIntermediate := range operationIntermediate.Responses {
-			err := responseIntermediate.Type.DefineDefinitions(operationIntermediate.PackagePath)
+			err := bindAnnotationType(responseIntermediate.Type, operationIntermediate.PackagePath, responseIntermediate.Pos)
+			if err != nil {
+				return errors.Stack(err)
+			}
+
+			err = responseIntermediate.Type.DefineDefinitions()
 			if err != nil {
 				return errors.Stack(err)
 			}
 		}
+		for i := range operationIntermediate.Headers {
+			bindHeaderType(&operationIntermediate.Headers[i], operationIntermediate.PackagePath)
+		}
 		for _, parameterIntermediate := range operationIntermediate.Parameters {
-			err := parameterIntermediate.Type.DefineDefinitions(operationIntermediate.PackagePath)
+			if parameterIntermediate.IsFile() {
+				continue
+			}
+
+			err := bindAnnotationType(parameterIntermediate.Type, operationIntermediate.PackagePath, parameterIntermediate.Pos)
+			if err != nil {
+				return errors.Stack(err)
+			}
+
+			err = parameterIntermediate.Type.DefineDefinitions()
 			if err != nil {
 				return errors.Stack(err)
 			}
@@ -24,28 +41,69 @@ func deriveDefinitionsFromOperations(operationIntermediates []OperationIntermedi
 	return nil
 }
 
-// What packages could have possibly contained this type?
-func possibleImportPaths(pkgInfo PackageInfo, goType string) []string {
+/*
+Types referenced in annotations are only known by the text of the annotation.
+This binds them to the type objects they refer to, as seen from the position of
+the annotation.
+*/
+func bindAnnotationType(schemer SchemerDefiner, pkgPath string, pos token.Pos) error {
 
-	if !strings.Contains(goType, ".") {
-		return []string{pkgInfo.ImportPath}
+	var member *MemberIntermediate
+
+	switch t := schemer.(type) {
+	case *MemberIntermediate:
+		member = t
+	case *SliceIntermediate:
+		return bindAnnotationType(t.ValueType, pkgPath, pos)
+	case *MapIntermediate:
+		return bindAnnotationType(t.ValueType, pkgPath, pos)
+	default:
+		return errors.Newf("Unexpected annotation type: %T", schemer)
 	}
 
-	chunks := strings.Split(goType, ".")
+	member.Pos = pos
 
-	alias := chunks[0]
+	if member.Type == "nil" {
+		return nil
+	}
 
-	importPaths := make([]string, 0)
+	if isPrimitive, _, _ := IsPrimitive(member.Type); isPrimitive {
+		return nil
+	}
 
-	for importPath, aliases := range pkgInfo.Imports {
-		for _, alias_ := range aliases {
-			if alias_ == alias {
-				// I'm pretty sure that there should never be duplicate importPaths here.
-				// Otherwise, check for duplicates.
-				importPaths = append(importPaths, importPath)
-			}
-		}
+	// A type that can't be resolved is documented as an arbitrary object,
+	// rather than holding up the rest of the document.
+
+	t, err := evalTypeExpression(pkgPath, pos, member.Type)
+	if err != nil {
+		reportProblem(pos, "unresolved-type", "Annotation type could not be resolved: %s", member.Type)
+		member.Type = "interface{}"
+		return nil
+	}
+
+	member.Object = namedObject(t)
+	member.TypeArgs = typeArguments(t)
+	if member.Object == nil {
+		reportProblem(pos, "unresolved-type", "Annotation type is neither primitive nor a named type: %s", member.Type)
+		member.Type = "interface{}"
+		return nil
+	}
+
+	return nil
+}
+
+// Binds the type of a response header, which may be mapped by the
+// configuration. Other types are reported when the header is described.
+func bindHeaderType(header *ResponseHeaderIntermediate, pkgPath string) {
+
+	if isPrimitive, _, _ := IsPrimitive(header.Type); isPrimitive {
+		return
+	}
+
+	t, err := evalTypeExpression(pkgPath, header.Pos, header.Type)
+	if err != nil {
+		return
 	}
 
-	return importPaths
+	header.Object = namedObject(t)
 }
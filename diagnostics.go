@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+)
+
+/*
+Problems found while generating the document are reported as diagnostics, rather
+than logged directly. Each one records where the problem was found (when it
+can be attributed to a place in the source), how severe it is, and a short code
+that identifies the kind of problem, so that tools can filter on it.
+
+Diagnostics are printed to stderr as soon as they're reported, either as text:
+
+	api/contacts.go:42: warning: Annotation could not be parsed: @Sucess 200 {object} Contact "Success" [unparsed-annotation]
+
+or as JSON, one object per line, when '-diagnostics-format json' is used.
+*/
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+}
+
+// Every diagnostic reported so far.
+var diagnostics []Diagnostic = make([]Diagnostic, 0)
+
+func (this Diagnostic) String() string {
+
+	s := string(this.Severity) + ": " + this.Message + " [" + this.Code + "]"
+
+	if this.File != "" {
+		s = fmt.Sprintf("%s:%d: %s", this.File, this.Line, s)
+	}
+
+	return s
+}
+
+/*
+Records and prints a diagnostic. The position may be token.NoPos when the
+problem can't be attributed to a place in the source.
+*/
+func diagnose(severity Severity, pos token.Pos, code string, format string, args ...interface{}) {
+
+	diagnostic := Diagnostic{
+		Severity: severity,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+	}
+
+	if pos.IsValid() {
+		position := fileSet.Position(pos)
+		diagnostic.File = position.Filename
+		diagnostic.Line = position.Line
+		diagnostic.Column = position.Column
+	}
+
+	diagnostics = append(diagnostics, diagnostic)
+
+	switch *diagnosticsFormat {
+	case "json":
+		b, err := json.Marshal(diagnostic)
+		if err != nil {
+			// There's nothing in a diagnostic that can't be marshaled.
+			panic(err)
+		}
+		fmt.Fprintln(os.Stderr, string(b))
+	default:
+		fmt.Fprintln(os.Stderr, diagnostic.String())
+	}
+}
+
+// Formats a position as 'file:line', the way diagnostics refer to each other.
+func formatPos(pos token.Pos) string {
+	if !pos.IsValid() {
+		return "an unknown position"
+	}
+
+	position := fileSet.Position(pos)
+	return fmt.Sprintf("%s:%d", position.Filename, position.Line)
+}
+
+func reportError(pos token.Pos, code string, format string, args ...interface{}) {
+	diagnose(SeverityError, pos, code, format, args...)
+}
+
+func reportWarning(pos token.Pos, code string, format string, args ...interface{}) {
+	diagnose(SeverityWarning, pos, code, format, args...)
+}
+
+/*
+Reports a problem that leaves the document incomplete or wrong, such as an
+annotation that can't be parsed. These are warnings, unless the '-strict' flag
+is used, in which case they're errors.
+*/
+func reportProblem(pos token.Pos, code string, format string, args ...interface{}) {
+	if *strict {
+		reportError(pos, code, format, args...)
+	} else {
+		reportWarning(pos, code, format, args...)
+	}
+}
+
+func countErrors() int {
+	n := 0
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+func reportInfo(pos token.Pos, code string, format string, args ...interface{}) {
+	diagnose(SeverityInfo, pos, code, format, args...)
+}
@@ -1,22 +1,38 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/go-openapi/spec"
 	"github.com/jackmanlabs/errors"
+	"go/types"
+	"sort"
 	"strconv"
 	"strings"
 )
 
 type DefinitionIntermediate struct {
-	Comment        string
-	Documentation  string
-	EmbeddedTypes  []string
-	Members        map[string]SchemerDefiner // map[name]schemer
-	Name           string
-	PackageName    string   // The actual package name of this type.
-	PackagePath    string   // The actual package path of this type.
-	UnderlyingType string   // This isn't used right now. In our test codebase, non-struct types were never used.
-	Enums          []string // If the underlying type is a primitive type, it's assumed it's an enum type, these being the values.
+	Comment          string
+	Documentation    string
+	EmbeddedTypes    []*types.Named
+	Members          map[string]SchemerDefiner // map[name]schemer
+	Name             string
+	Object           *types.TypeName // The type checker's object for this type.
+	TypeArgs         []types.Type    // If this is an instantiation of a generic type, its type arguments.
+	PackageName      string          // The actual package name of this type.
+	PackagePath      string          // The actual package path of this type.
+	UnderlyingType   string          // This isn't used right now. In our test codebase, non-struct types were never used.
+	Enums            []string        // If the underlying type is a primitive type, it's assumed it's an enum type, these being the values.
+	EnumNames        []string        // The names of the constants declaring the enum values.
+	EnumDescriptions []string        // The documentation of the constants declaring the enum values.
+	EnumStrings      bool            // The enum values are the strings written by the MarshalText or MarshalJSON method.
+	CustomName       string          // If set by the @SwaggerName tag, overrides the name derived from the naming convention.
+	UniqueName       string          // If set, overrides the name derived from the naming convention, to avoid a collision.
+	WireType         string          // If set, the type is marshaled as this Swagger type, rather than as its Go type suggests.
+	WireFormat       string          // The Swagger format that goes along with the WireType.
+	MarshalsItself   bool            // The type implements json.Marshaler, and we don't know what it produces.
+	Composite        SchemerDefiner  // If the underlying type is a slice, array or map, its description.
+	Xml              *spec.XMLObject // The root element, if the type has any XML tags.
 
 	// While it may not strictly be equivalent from a language specification
 	// perspective, we're going to call a non-struct type with an underlying
@@ -34,6 +50,14 @@ func (this *DefinitionIntermediate) CanonicalName() string {
 
 func (this *DefinitionIntermediate) SwaggerName() string {
 
+	if this.CustomName != "" {
+		return this.CustomName
+	}
+
+	if this.UniqueName != "" {
+		return this.UniqueName
+	}
+
 	var name string
 
 	switch *naming {
@@ -53,13 +77,21 @@ func (this *DefinitionIntermediate) Schema() spec.Schema {
 	var schema spec.Schema
 	schema.Title = this.SwaggerName()
 
-	if isPrimitive, t, f := IsPrimitive(this.UnderlyingType); isPrimitive {
+	if this.WireType != "" {
+		schema.Typed(this.WireType, this.WireFormat)
+	} else if this.MarshalsItself {
+		// Anything goes.
+	} else if isPrimitive, t, f := IsPrimitive(this.UnderlyingType); isPrimitive {
 		schema.Typed(t, f)
 		schema.Enum = make([]interface{}, 0)
 
 		for _, enum := range this.Enums {
 			if strings.HasPrefix(enum, "\"") {
-				schema.Enum = append(schema.Enum, strings.Trim(enum, "\""))
+				if s, err := strconv.Unquote(enum); err == nil {
+					schema.Enum = append(schema.Enum, s)
+				} else {
+					schema.Enum = append(schema.Enum, strings.Trim(enum, "\""))
+				}
 			} else {
 				// store numerical enums as numbers, otherwise strings.
 				if f, err := strconv.ParseFloat(enum, 64); err == nil {
@@ -70,27 +102,207 @@ func (this *DefinitionIntermediate) Schema() spec.Schema {
 				}
 			}
 		}
-	} else {
-		schema.Typed("object", "")
-		schema.Required = make([]string, 0)
 
-		properties := make(map[string]spec.Schema)
-		for _, member := range this.Members {
-			property := member.Schema()
-			properties[property.Title] = *property
+		hasNames := len(schema.Enum) > 0 && len(this.EnumNames) == len(schema.Enum) && len(this.EnumDescriptions) == len(schema.Enum)
+
+		// Client generators use these to name the members of their enums.
+		if hasNames {
+			schema.AddExtension("x-enum-varnames", this.EnumNames)
+			schema.AddExtension("x-enum-descriptions", this.EnumDescriptions)
+			schema.Description = enumTable(schema.Enum, this.EnumNames, this.EnumDescriptions)
+		}
 
-			if member.IsRequired() {
-				schema.Required = append(schema.Required, property.Title)
+		// JSON Schema 2020-12 lets us name each of the values.
+		if isJsonSchema2020() && hasNames {
+			schema.OneOf = make([]spec.Schema, 0)
+			for i, enum := range schema.Enum {
+				value := spec.Schema{}
+				value.Title = this.EnumNames[i]
+				value.Description = this.EnumDescriptions[i]
+				withExtraProp(&value, "const", enum)
+				schema.OneOf = append(schema.OneOf, value)
+			}
+			schema.Enum = nil
+		}
+	} else if this.Composite != nil {
+		schema = *this.Composite.Schema()
+		schema.Title = this.SwaggerName()
+	} else if *embedding == "allOf" && len(this.EmbeddedTypes) > 0 {
+		// Each embedded struct is described by its own model, which this one
+		// adds its own fields to.
+		schema.AllOf = make([]spec.Schema, 0)
+		for _, embeddedType := range this.EmbeddedTypes {
+			if definition, ok := definitionStore.ExistsDefinition(embeddedType.Obj(), typeArguments(embeddedType)); ok {
+				schema.AllOf = append(schema.AllOf, *spec.RefSchema(definitionsPath() + definition.SwaggerName()))
 			}
+		}
+		if len(this.Members) > 0 {
+			schema.AllOf = append(schema.AllOf, objectSchema(this.promotedMembers(false)))
+		}
+	} else {
+		title := schema.Title
+		schema = objectSchema(this.promotedMembers(true))
+		schema.Title = title
+	}
+
+	if this.Xml != nil {
+		schema.XML = this.Xml
+	}
+
+	return schema
+}
+
+func objectSchema(members map[string]SchemerDefiner) spec.Schema {
 
+	var schema spec.Schema
+	schema.Typed("object", "")
+	schema.Required = make([]string, 0)
+
+	properties := make(map[string]spec.Schema)
+	for _, member := range members {
+		property := member.Schema()
+		properties[property.Title] = *property
+
+		if member.IsRequired() {
+			schema.Required = append(schema.Required, property.Title)
 		}
 
-		schema.Properties = properties
 	}
 
+	schema.Properties = properties
+
+	// Keep the output stable from one run to the next.
+	sort.Strings(schema.Required)
+
 	return schema
 }
 
+/*
+Returns the members of the definition, along with those promoted from the
+structs it embeds (if they're included), keyed by their JSON names. The rules
+are those of encoding/json:
+
+  - A field hides the fields of the same name that are embedded more deeply.
+  - Of the fields of the same name at the same depth, the one whose JSON tag
+    names it wins. If there isn't exactly one such field, they're all dropped.
+  - Each embedded struct is only considered at the shallowest depth at which
+    it's embedded.
+*/
+func (this *DefinitionIntermediate) promotedMembers(includeEmbedded bool) map[string]SchemerDefiner {
+
+	type candidate struct {
+		Member SchemerDefiner
+		Depth  int
+		Tagged bool
+	}
+
+	// map[JSON name]candidates
+	candidates := make(map[string][]candidate)
+	visited := make(map[*DefinitionIntermediate]bool)
+
+	current := []*DefinitionIntermediate{this}
+	for depth := 0; len(current) > 0; depth++ {
+		next := make([]*DefinitionIntermediate, 0)
+
+		for _, definition := range current {
+			if visited[definition] {
+				continue
+			}
+
+			for _, member := range definition.Members {
+				name, tagged := memberJsonName(member)
+				candidates[name] = append(candidates[name], candidate{Member: member, Depth: depth, Tagged: tagged})
+			}
+
+			if !includeEmbedded {
+				continue
+			}
+
+			for _, embeddedType := range definition.EmbeddedTypes {
+				if embedded, ok := definitionStore.ExistsDefinition(embeddedType.Obj(), typeArguments(embeddedType)); ok {
+					next = append(next, embedded)
+				}
+			}
+		}
+
+		// A struct embedded twice at the same depth is seen twice, so that its
+		// fields conflict with each other.
+		for _, definition := range current {
+			visited[definition] = true
+		}
+
+		current = next
+	}
+
+	members := make(map[string]SchemerDefiner)
+
+	for name, fields := range candidates {
+		dominant := make([]candidate, 0)
+		tagged := make([]candidate, 0)
+
+		for _, field := range fields {
+			if field.Depth > fields[0].Depth {
+				// Candidates are found in order of depth.
+				break
+			}
+			dominant = append(dominant, field)
+			if field.Tagged {
+				tagged = append(tagged, field)
+			}
+		}
+
+		if len(dominant) == 1 {
+			members[name] = dominant[0].Member
+		} else if len(tagged) == 1 {
+			members[name] = tagged[0].Member
+		}
+	}
+
+	return members
+}
+
+// Returns the JSON name of the member, and whether it's given by a JSON tag.
+func memberJsonName(member SchemerDefiner) (string, bool) {
+
+	var name, jsonName string
+
+	switch t := member.(type) {
+	case *MemberIntermediate:
+		name, jsonName = t.Name, t.JsonName
+	case *SliceIntermediate:
+		name, jsonName = t.Name, t.JsonName
+	case *MapIntermediate:
+		name, jsonName = t.Name, t.JsonName
+	}
+
+	if jsonName != "" {
+		return jsonName, true
+	}
+
+	return name, false
+}
+
+/*
+Describes the values of an enum for human readers, as a markdown table:
+
+	| Value | Name | Description |
+	|-------|------|-------------|
+	| 1 | KindPerson | A natural person. |
+*/
+func enumTable(values []interface{}, names, descriptions []string) string {
+
+	escape := strings.NewReplacer("|", "\\|", "\n", " ")
+
+	b := new(bytes.Buffer)
+	b.WriteString("| Value | Name | Description |\n")
+	b.WriteString("|-------|------|-------------|\n")
+	for i, value := range values {
+		fmt.Fprintf(b, "| %s | %s | %s |\n", escape.Replace(fmt.Sprint(value)), names[i], escape.Replace(descriptions[i]))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
 func (this *DefinitionIntermediate) DefineDefinitions() error {
 
 	var err error
@@ -101,23 +313,35 @@ func (this *DefinitionIntermediate) DefineDefinitions() error {
 	}
 
 	for _, embeddedType := range this.EmbeddedTypes {
-		definition, ok := definitionStore.ExistsDefinition(this.PackagePath, embeddedType)
+		definition, ok := definitionStore.ExistsDefinition(embeddedType.Obj(), typeArguments(embeddedType))
 		if !ok {
-			definition, err = findDefinition(this.PackagePath, embeddedType)
+			definition, err = findDefinition(embeddedType.Obj(), typeArguments(embeddedType))
 			if err != nil {
 				return errors.Stack(err)
 			} else if definition == nil {
-				return errors.Newf("Failed to find definition for embedded member: %s:%s", goType, embeddedType)
+				return errors.Newf("Failed to find definition for embedded member: %s:%s", goType, embeddedType.Obj().Name())
 			}
 
 			definitionStore.Add(definition)
-		}
 
-		mergeDefinitions(this, definition)
+			// The embedded struct is a model of its own, which needs the
+			// definitions of its members.
+			err = definition.DefineDefinitions()
+			if err != nil {
+				return errors.Stack(err)
+			}
+		}
 	}
 
 	for _, member := range this.Members {
-		err := member.DefineDefinitions(this.PackagePath)
+		err := member.DefineDefinitions()
+		if err != nil {
+			return errors.Stack(err)
+		}
+	}
+
+	if this.Composite != nil {
+		err := this.Composite.DefineDefinitions()
 		if err != nil {
 			return errors.Stack(err)
 		}
@@ -5,7 +5,7 @@ import "github.com/go-openapi/spec"
 type SchemerDefiner interface {
 	// This method should have the side effect of updating package information
 	// on the receiver object.
-	DefineDefinitions(referencingPackagePath string) error
+	DefineDefinitions() error
 	Schema() *spec.Schema
 	IsRequired() bool
 }
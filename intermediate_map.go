@@ -11,8 +11,8 @@ type MapIntermediate struct {
 	Type          string // Go type (how it was originally described)
 	JsonName      string // JSON name.
 	JsonOmitEmpty bool   // If the omitempty flag was given in the JSON.
-	KeyType       *MemberIntermediate
-	ValueType     *MemberIntermediate
+	KeyType       SchemerDefiner
+	ValueType     SchemerDefiner
 	Description   string
 	Validations   Validator
 	Deprecated    bool
@@ -82,14 +82,14 @@ func (this *MapIntermediate) Schema() *spec.Schema {
 	return schema
 }
 
-func (this *MapIntermediate) DefineDefinitions(referencingPackagePath string) error {
+func (this *MapIntermediate) DefineDefinitions() error {
 
-	err := this.ValueType.DefineDefinitions(referencingPackagePath)
+	err := this.ValueType.DefineDefinitions()
 	if err != nil {
 		return errors.Stack(err)
 	}
 
-	err = this.KeyType.DefineDefinitions(referencingPackagePath)
+	err = this.KeyType.DefineDefinitions()
 	if err != nil {
 		return errors.Stack(err)
 	}
@@ -4,6 +4,8 @@ import (
 	"github.com/go-openapi/jsonreference"
 	"github.com/go-openapi/spec"
 	"github.com/jackmanlabs/errors"
+	"go/token"
+	"go/types"
 	"log"
 	"strconv"
 	"strings"
@@ -12,10 +14,16 @@ import (
 type MemberIntermediate struct {
 	PackageName   string // Necessary for canonical and swagger names.
 	PackagePath   string
-	Name          string // Name in Go struct.
-	Type          string // Go type
-	JsonName      string // JSON name.
-	JsonOmitEmpty bool   // If the omitempty flag was given in the JSON.
+	Name          string                  // Name in Go struct.
+	Type          string                  // Go type
+	Object        *types.TypeName         // The named type referred to, if the type isn't primitive.
+	TypeArgs      []types.Type            // The type arguments of the named type, if it's an instantiation of a generic type.
+	Inline        *DefinitionIntermediate // If the type is an anonymous struct, its definition, which isn't stored.
+	Pos           token.Pos               // Where the type was declared, if known, for diagnostics.
+	JsonName      string                  // JSON name.
+	JsonOmitEmpty bool                    // If the omitempty or omitzero flag was given in the JSON.
+	JsonString    bool                    // If the string flag was given in the JSON.
+	Xml           *XmlTag                 // The XML tag, if one was given.
 	Description   string
 	Validations   Validator
 	Deprecated    bool
@@ -26,11 +34,18 @@ func (this *MemberIntermediate) IsRequired() bool {
 }
 
 func (this *MemberIntermediate) DefinitionRef() string {
-	return "#/definitions/" + this.SwaggerName()
+	return definitionsPath() + this.SwaggerName()
 }
 
 func (this *MemberIntermediate) SwaggerName() string {
 
+	// The definition knows best, in case its name had to be disambiguated.
+	if this.Object != nil {
+		if definition, ok := definitionStore.ExistsDefinition(this.Object, this.TypeArgs); ok {
+			return definition.SwaggerName()
+		}
+	}
+
 	goType := this.Type
 	goType = strings.TrimPrefix(goType, "*")
 
@@ -80,8 +95,19 @@ func (this *MemberIntermediate) Schema() *spec.Schema {
 	schema.Title = name
 	schema.Description = this.Description
 
-	if isPrimitive, t, f := IsPrimitive(this.Type); isPrimitive {
-		schema.Typed(t, f)
+	// Types mapped by the configuration are described as if they were
+	// primitive, including their validations.
+	mapping, isMapped := mappedType(this.Object)
+
+	isPrimitive, t, f := IsPrimitive(this.Type)
+	if isMapped {
+		isPrimitive, t, f = true, mapping.Type, mapping.Format
+	}
+
+	if isPrimitive {
+		if t != "" {
+			schema.Typed(t, f)
+		}
 
 		if t == "string" {
 			if this.Validations.Min() >= 0 {
@@ -131,14 +157,19 @@ func (this *MemberIntermediate) Schema() *spec.Schema {
 			}
 
 			if this.Validations.GreaterThan() >= 0 {
-				schema.WithMinimum(this.Validations.GreaterThan(), true)
+				withExclusiveMinimum(schema, this.Validations.GreaterThan())
 			}
 
 			if this.Validations.LessThan() >= 0 {
-				schema.WithMaximum(this.Validations.LessThan(), true)
+				withExclusiveMaximum(schema, this.Validations.LessThan())
 			}
 		}
 
+	} else if this.Inline != nil {
+		inline := this.Inline.Schema()
+		inline.Title = schema.Title
+		inline.Description = schema.Description
+		*schema = inline
 	} else {
 		ref, err := jsonreference.New(this.DefinitionRef())
 		if err != nil {
@@ -147,36 +178,134 @@ func (this *MemberIntermediate) Schema() *spec.Schema {
 		schema.Ref = spec.Ref{Ref: ref}
 	}
 
+	// The string flag makes encoding/json write the value inside a string.
+	if this.JsonString && !isMapped {
+		quoteSchema(schema, this.quotedType())
+	}
+
+	if isMapped {
+		schema = mapping.apply(schema)
+	}
+
+	if this.Xml != nil {
+		this.Xml.apply(schema)
+	}
+
+	// encoding/json writes nil pointers as null, unless it leaves them out.
+	if strings.HasPrefix(this.Type, "*") && !this.JsonOmitEmpty && !(isMapped && mapping.Nullable) {
+		schema = nullableSchema(schema)
+	}
+
 	return schema
 }
 
-func (this *MemberIntermediate) DefineDefinitions(referringPackage string) error {
+/*
+Returns the basic type that encoding/json decides whether the string flag applies
+by, which is the underlying type of a named type. Nil is returned if the type
+isn't a basic type, or if it marshals itself, in which case the flag is ignored.
+*/
+func (this *MemberIntermediate) quotedType() *types.Basic {
+
+	if this.Object == nil {
+		obj, ok := types.Universe.Lookup(strings.TrimPrefix(this.Type, "*")).(*types.TypeName)
+		if !ok {
+			return nil
+		}
+		basic, _ := obj.Type().(*types.Basic)
+		return basic
+	}
 
-	if referringPackage == "" {
-		return errors.New("Referencing Package Path is empty.")
+	if lookupStringerMethod(this.Object, "MarshalJSON") != nil || lookupStringerMethod(this.Object, "MarshalText") != nil {
+		return nil
 	}
 
+	basic, _ := this.Object.Type().Underlying().(*types.Basic)
+	return basic
+}
+
+/*
+Describes a value that encoding/json writes inside a string, because of the
+string flag. The flag only applies to strings, numbers and booleans; other types
+are left as they are. A named type is no longer described by its model, since
+its values are written differently.
+*/
+func quoteSchema(schema *spec.Schema, basic *types.Basic) {
+
+	if basic == nil {
+		return
+	}
+
+	var pattern string
+
+	info := basic.Info()
+	switch {
+	case info&types.IsString != 0:
+		pattern = `^".*"$`
+	case info&types.IsBoolean != 0:
+		pattern = `^(true|false)$`
+	case info&types.IsFloat != 0:
+		pattern = `^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`
+	case info&types.IsUnsigned != 0:
+		pattern = `^[0-9]+$`
+	case info&types.IsInteger != 0:
+		pattern = `^-?[0-9]+$`
+	default:
+		return
+	}
+
+	schema.Ref = spec.Ref{}
+	schema.Type = nil
+	schema.Format = ""
+	schema.Typed("string", "")
+	schema.WithPattern(pattern)
+}
+
+func (this *MemberIntermediate) DefineDefinitions() error {
+
 	var err error
 
 	goType := this.Type
 
-	if goType == "nil"{
+	if goType == "nil" {
 		return nil
 	}
 
+	if this.Inline != nil {
+		return this.Inline.DefineDefinitions()
+	}
+
 	if isPrimitive, _, _ := IsPrimitive(goType); isPrimitive {
 		return nil
 	}
 
+	if _, isMapped := mappedType(this.Object); isMapped {
+		return nil
+	}
+
+	// A type that can't be defined is documented as an arbitrary object,
+	// rather than by a reference to a model that doesn't exist.
+
+	if this.Object == nil {
+		reportProblem(this.Pos, "unresolved-type", "Type of %s could not be resolved: %s", this.Name, goType)
+		this.Type = "interface{}"
+		return nil
+	}
+
 	var definition *DefinitionIntermediate
-	definition, ok := definitionStore.ExistsDefinition(referringPackage, goType)
+	definition, ok := definitionStore.ExistsDefinition(this.Object, this.TypeArgs)
 
 	if !ok {
-		definition, err = findDefinition(referringPackage, goType)
-		if err != nil {
-			return errors.Stack(err)
-		} else if definition == nil {
-			return errors.New("Failed to generate definition for type: " + goType)
+		definition, err = findDefinition(this.Object, this.TypeArgs)
+		if err != nil || definition == nil {
+			reason := "its declaration wasn't found"
+			if err != nil {
+				reason = err.Error()
+			}
+			reportProblem(this.Pos, "unresolved-type", "Type of %s could not be defined: %s (%s)", this.Name, goType, reason)
+			this.Type = "interface{}"
+			this.Object = nil
+			this.TypeArgs = nil
+			return nil
 		}
 
 		definitionStore.Add(definition)
@@ -187,7 +316,10 @@ func (this *MemberIntermediate) DefineDefinitions(referringPackage string) error
 
 	if !ok {
 		// This triggers the definition of all the members of the discovered type associated with the present member.
-		definition.DefineDefinitions()
+		err = definition.DefineDefinitions()
+		if err != nil {
+			return errors.Stack(err)
+		}
 	}
 
 	return nil
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"github.com/go-openapi/spec"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+/*
+A security scheme, as declared by a @SecurityDefinition tag. Which of the
+properties are relevant depends on the type of the scheme:
+
+	basic:  none
+	apiKey: In, ParamName
+	oauth2: Flow, AuthorizationUrl and/or TokenUrl, Scopes
+*/
+type SecurityDefinitionIntermediate struct {
+	Name             string
+	Type             string // One of 'basic', 'apiKey', or 'oauth2'.
+	Description      string
+	In               string // Either 'header' or 'query'.
+	ParamName        string // The name of the header or query parameter.
+	Flow             string // One of 'implicit', 'password', 'application', or 'accessCode'.
+	AuthorizationUrl string
+	TokenUrl         string
+	Scopes           map[string]string // map[scope]description
+}
+
+/*
+A set of security schemes that must all be satisfied at once, along with the
+scopes required of each (map[scheme]scopes). An operation may list several of
+these, in which case any one of them is sufficient.
+*/
+type SecurityRequirementIntermediate map[string][]string
+
+func (this *SecurityDefinitionIntermediate) SecurityScheme() *spec.SecurityScheme {
+
+	var scheme *spec.SecurityScheme
+
+	switch this.Type {
+	case "basic":
+		scheme = spec.BasicAuth()
+	case "apiKey":
+		scheme = spec.APIKeyAuth(this.ParamName, this.In)
+	case "oauth2":
+		switch this.Flow {
+		case "implicit":
+			scheme = spec.OAuth2Implicit(this.AuthorizationUrl)
+		case "password":
+			scheme = spec.OAuth2Password(this.TokenUrl)
+		case "application":
+			scheme = spec.OAuth2Application(this.TokenUrl)
+		case "accessCode":
+			scheme = spec.OAuth2AccessToken(this.AuthorizationUrl, this.TokenUrl)
+		}
+
+		for scope, description := range this.Scopes {
+			scheme.AddScope(scope, description)
+		}
+	}
+
+	scheme.Description = this.Description
+
+	return scheme
+}
+
+/*
+Parses the arguments of a @SecurityDefinition tag. The second argument is the
+type of the scheme, and it determines the rest of the arguments:
+
+	@SecurityDefinition Basic basic "HTTP Basic Authentication"
+	@SecurityDefinition ApiToken apiKey header x-ems-api-token "Auth token, from /authenticate request"
+	@SecurityDefinition OAuth oauth2 implicit https://example.com/authorize "OAuth 2.0"
+	@SecurityDefinition OAuth oauth2 password https://example.com/token
+	@SecurityDefinition OAuth oauth2 application https://example.com/token
+	@SecurityDefinition OAuth oauth2 accessCode https://example.com/authorize https://example.com/token
+*/
+func parseSecurityDefinition(name, schemeType, args string) (SecurityDefinitionIntermediate, bool) {
+
+	var (
+		rxBasic  *regexp.Regexp = regexp.MustCompile(`^\s*(?:\"(.+)\")?\s*$`)
+		rxApiKey *regexp.Regexp = regexp.MustCompile(`^\s*(header|query)\s+([\w\.-]+)\s*(?:\"(.+)\")?\s*$`)
+		rxOAuth2 *regexp.Regexp = regexp.MustCompile(`^\s*(implicit|password|application|accessCode)\s+([^\s\"]+)(?:\s+([^\s\"]+))?\s*(?:\"(.+)\")?\s*$`)
+	)
+
+	definition := SecurityDefinitionIntermediate{
+		Name:   name,
+		Scopes: make(map[string]string),
+	}
+
+	switch strings.ToLower(schemeType) {
+	case "basic":
+		if !rxBasic.MatchString(args) {
+			return definition, false
+		}
+
+		matches := rxBasic.FindStringSubmatch(args)
+		definition.Type = "basic"
+		definition.Description = matches[1]
+
+	case "apikey":
+		if !rxApiKey.MatchString(args) {
+			return definition, false
+		}
+
+		matches := rxApiKey.FindStringSubmatch(args)
+		definition.Type = "apiKey"
+		definition.In = matches[1]
+		definition.ParamName = matches[2]
+		definition.Description = matches[3]
+
+	case "oauth2":
+		if !rxOAuth2.MatchString(args) {
+			return definition, false
+		}
+
+		matches := rxOAuth2.FindStringSubmatch(args)
+		definition.Type = "oauth2"
+		definition.Flow = matches[1]
+		definition.Description = matches[4]
+
+		switch definition.Flow {
+		case "implicit":
+			definition.AuthorizationUrl = matches[2]
+		case "password", "application":
+			definition.TokenUrl = matches[2]
+		case "accessCode":
+			if matches[3] == "" {
+				return definition, false
+			}
+			definition.AuthorizationUrl = matches[2]
+			definition.TokenUrl = matches[3]
+		}
+
+		if definition.Flow != "accessCode" && matches[3] != "" {
+			return definition, false
+		}
+
+	default:
+		return definition, false
+	}
+
+	return definition, true
+}
+
+/*
+Parses the arguments of a @Security tag. Schemes that must all be satisfied are
+joined with an ampersand, and the scopes required of a scheme are listed in
+square brackets:
+
+	@Security ApiToken
+	@Security Consumer & ApiToken
+	@Security OAuth [read:contacts, write:contacts]
+
+The special value 'none' returns an empty requirement, which is used to opt an
+operation out of the default security requirements.
+*/
+func parseSecurityRequirement(args string) (SecurityRequirementIntermediate, bool) {
+
+	rxScheme := regexp.MustCompile(`^\s*([\w\.-]+)\s*(?:\[([^\]]*)\])?\s*$`)
+
+	requirement := make(SecurityRequirementIntermediate)
+
+	if strings.TrimSpace(args) == "none" {
+		return requirement, true
+	}
+
+	for _, part := range strings.Split(args, "&") {
+		if !rxScheme.MatchString(part) {
+			return nil, false
+		}
+
+		matches := rxScheme.FindStringSubmatch(part)
+
+		scopes := make([]string, 0)
+		for _, scope := range strings.FieldsFunc(matches[2], func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			scopes = append(scopes, scope)
+		}
+
+		requirement[matches[1]] = scopes
+	}
+
+	return requirement, true
+}
+
+/*
+Appends a requirement to a list of requirements. The empty requirement ('none')
+clears the list instead, and is remembered by leaving the list empty but not
+nil.
+*/
+func appendSecurityRequirement(requirements []SecurityRequirementIntermediate, requirement SecurityRequirementIntermediate) []SecurityRequirementIntermediate {
+
+	if len(requirement) == 0 {
+		return make([]SecurityRequirementIntermediate, 0)
+	}
+
+	return append(requirements, requirement)
+}
+
+func swaggerizeSecurityRequirements(requirements []SecurityRequirementIntermediate) []map[string][]string {
+
+	if requirements == nil {
+		return nil
+	}
+
+	security := make([]map[string][]string, 0)
+	for _, requirement := range requirements {
+		security = append(security, map[string][]string(requirement))
+	}
+
+	return security
+}
+
+/*
+Warns about requirements that refer to schemes that haven't been declared with
+a @SecurityDefinition tag.
+*/
+func checkSecurityRequirements(apiIntermediate ApiIntermediate, operationIntermediates []OperationIntermediate) {
+
+	defined := make(map[string]bool)
+	for _, definition := range apiIntermediate.SecurityDefinitions {
+		defined[definition.Name] = true
+	}
+
+	check := func(where string, pos token.Pos, requirements []SecurityRequirementIntermediate) {
+		for _, requirement := range requirements {
+			for scheme := range requirement {
+				if !defined[scheme] {
+					reportWarning(pos, "unknown-security-scheme", "Undefined security scheme (%s) required by %s.", scheme, where)
+				}
+			}
+		}
+	}
+
+	check("the API", token.NoPos, apiIntermediate.Security)
+
+	for _, operationIntermediate := range operationIntermediates {
+		check(operationIntermediate.Method+" "+operationIntermediate.Path, operationIntermediate.Pos, operationIntermediate.Security)
+	}
+}
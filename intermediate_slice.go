@@ -11,10 +11,13 @@ type SliceIntermediate struct {
 	Type          string // Go type
 	JsonName      string // JSON name.
 	JsonOmitEmpty bool   // If the omitempty flag was given in the JSON.
-	ValueType     *MemberIntermediate
+	ValueType     SchemerDefiner
 	Description   string
 	Validations   Validator
 	Deprecated    bool
+	IsArray       bool    // If the Go type is an array, rather than a slice.
+	Length        int64   // The length of the array.
+	Xml           *XmlTag // The XML tag, if one was given.
 }
 
 func (this *SliceIntermediate) IsRequired() bool {
@@ -38,6 +41,12 @@ func (this *SliceIntermediate) Schema() *spec.Schema {
 
 	schema.Items.Schema = this.ValueType.Schema()
 
+	// An array is always written with all of its elements.
+	if this.IsArray {
+		schema.WithMinItems(this.Length)
+		schema.WithMaxItems(this.Length)
+	}
+
 	if this.Validations.Min() >= 0 {
 		schema.WithMinItems(int64(this.Validations.Min()))
 	}
@@ -67,12 +76,16 @@ func (this *SliceIntermediate) Schema() *spec.Schema {
 		schema.WithMaxItems(int64(this.Validations.LessThan() - 1))
 	}
 
+	if this.Xml != nil {
+		this.Xml.apply(schema)
+	}
+
 	return schema
 }
 
-func (this *SliceIntermediate) DefineDefinitions(referencingPackagePath string) error {
+func (this *SliceIntermediate) DefineDefinitions() error {
 
-	err := this.ValueType.DefineDefinitions(referencingPackagePath)
+	err := this.ValueType.DefineDefinitions()
 	if err != nil {
 		return errors.Stack(err)
 	}
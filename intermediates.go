@@ -1,10 +1,11 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
 	"github.com/go-openapi/spec"
+	"go/token"
+	"go/types"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -15,6 +16,11 @@ type ApiIntermediate struct {
 	ApiDescription string
 	BasePath       string
 	SubApis        []SubApiIntermediate
+	Accepts        []string // The default for all operations.
+	Produces       []string // The default for all operations.
+
+	SecurityDefinitions []SecurityDefinitionIntermediate
+	Security            []SecurityRequirementIntermediate // The default for all operations.
 }
 
 type SubApiIntermediate struct {
@@ -29,12 +35,19 @@ type OperationIntermediate struct {
 	Title       string
 	Description string
 	Accepts     []string
+	Produces    []string
 	Parameters  []ParameterIntermediate
 	Responses   []*ResponseIntermediate
+	Headers     []ResponseHeaderIntermediate
 	Path        string
 	Method      string
-	PackagePath string // Where this operation was found.
+	PackagePath string    // Where this operation was found.
+	Pos         token.Pos // Where this operation was found, more precisely.
 	Tag         string
+
+	// When nil, the operation falls back on the default security requirements
+	// of the API. When empty, the operation requires no security at all.
+	Security []SecurityRequirementIntermediate
 }
 
 type ParameterIntermediate struct {
@@ -42,17 +55,25 @@ type ParameterIntermediate struct {
 	Required    bool
 	Description string
 	Type        *MemberIntermediate
+	Pos         token.Pos
 }
 
 func (this *ParameterIntermediate) Schema() *spec.Schema {
 	return this.Type.Schema()
 }
 
+// Swagger's 'file' type is only allowed for form parameters, where it's taken
+// as such, rather than as a Go type.
+func (this *ParameterIntermediate) IsFile() bool {
+	return this.In == "formData" && this.Type.Type == "file"
+}
+
 type ResponseIntermediate struct {
 	Success     bool
 	StatusCode  int
 	Description string
 	Type        SchemerDefiner
+	Pos         token.Pos
 }
 
 func (this *ResponseIntermediate) Schema() *spec.Schema {
@@ -63,37 +84,94 @@ func (this *ResponseIntermediate) Schema() *spec.Schema {
 	return schema
 }
 
-func intermediatateApi(commentBlocks []string) ApiIntermediate {
+type ResponseHeaderIntermediate struct {
+	StatusCode  int
+	Name        string
+	Type        string
+	Object      *types.TypeName // The named type referred to, if the type isn't primitive.
+	Description string
+	Pos         token.Pos
+}
+
+func (this *ResponseHeaderIntermediate) Header() *spec.Header {
+
+	var pattern string
+
+	isPrimitive, t, format := IsPrimitive(this.Type)
+	if mapping, isMapped := mappedType(this.Object); isMapped && mapping.Type != "" {
+		isPrimitive, t, format = true, mapping.Type, mapping.Format
+		pattern = mapping.Pattern
+	}
+
+	if !isPrimitive {
+		reportWarning(this.Pos, "non-primitive-header", "It appears there is a non-primitive response header: %s (%s)", this.Name, this.Type)
+		t, format = "string", ""
+	}
+
+	header := spec.ResponseHeader()
+	header.Typed(t, format)
+	header.Description = this.Description
+
+	if pattern != "" {
+		header.WithPattern(pattern)
+	}
+
+	return header
+}
+
+func intermediatateApi(commentBlocks []CommentBlock) ApiIntermediate {
 
 	// @APIVersion 1.0.0
 	// @APITitle REST API
 	// @APIDescription EMS Rest API
 	// @BasePath /api/v1
 	// @SubApi HealthCheck [/health]
+	// @Accept json
+	// @Produce json
+	//
+	// @SecurityDefinition ApiToken apiKey header x-ems-api-token "Auth token, from /authenticate request"
+	// @SecurityDefinition OAuth oauth2 accessCode https://example.com/authorize https://example.com/token
+	// @SecurityScope OAuth read:timezones "Read access to time zones"
+	// @Security ApiToken
 
 	var (
 		// At the time of writing, IntelliJ erroneously warns on unnecessary
 		// escape sequences. Do not trust IntelliJ.
+		rxAccept         *regexp.Regexp = regexp.MustCompile(`@Accept\s+(.+)`)
+		rxProduce        *regexp.Regexp = regexp.MustCompile(`@Produce\s+(.+)`)
 		rxApiVersion     *regexp.Regexp = regexp.MustCompile(`@APIVersion\s+([\d\.]+)`)
 		rxApiTitle       *regexp.Regexp = regexp.MustCompile(`@APITitle\s+(.+)`)
 		rxApiDescription *regexp.Regexp = regexp.MustCompile(`@APIDescription\s+(.+)`)
 		rxBasePath       *regexp.Regexp = regexp.MustCompile(`@BasePath\s+([/a-zA-Z0-9-]+)`)
 		rxSubApi         *regexp.Regexp = regexp.MustCompile(`@SubApi\s+([0-9a-zA-Z]+)\s+\[([/a-zA-Z0-9-]+)\]`)
+		rxSecurityDef    *regexp.Regexp = regexp.MustCompile(`@SecurityDefinition\s+([\w\.-]+)\s+(\w+)(.*)`)
+		rxSecurityScope  *regexp.Regexp = regexp.MustCompile(`@SecurityScope\s+([\w\.-]+)\s+([^\s\"]+)\s*(?:\"(.+)\")?`)
+		rxSecurity       *regexp.Regexp = regexp.MustCompile(`@Security\s+(.+)`)
 	)
 
 	var apiIntermediate ApiIntermediate = ApiIntermediate{
-		SubApis: make([]SubApiIntermediate, 0),
+		SubApis:             make([]SubApiIntermediate, 0),
+		Accepts:             make([]string, 0),
+		Produces:            make([]string, 0),
+		SecurityDefinitions: make([]SecurityDefinitionIntermediate, 0),
 	}
 
-	for _, commentBlock := range commentBlocks {
+	// Scopes may be declared before the scheme they belong to.
+	// map[scheme]map[scope]description
+	scopes := make(map[string]map[string]string)
+	scopesPos := make(map[string]token.Pos)
 
-		b := bytes.NewBufferString(commentBlock)
-		scanner := bufio.NewScanner(b)
-		for scanner.Scan() {
-			line := scanner.Text()
+	for _, commentBlock := range commentBlocks {
+		for _, commentLine := range commentBlock.Lines {
+			line := commentLine.Text
+			pos := commentLine.Pos
 
 			switch {
 
+			case rxAccept.MatchString(line):
+				apiIntermediate.Accepts = append(apiIntermediate.Accepts, parseMediaTypes(rxAccept.FindStringSubmatch(line)[1], pos)...)
+			case rxProduce.MatchString(line):
+				apiIntermediate.Produces = append(apiIntermediate.Produces, parseMediaTypes(rxProduce.FindStringSubmatch(line)[1], pos)...)
 			case rxApiDescription.MatchString(line):
 				apiIntermediate.ApiDescription = rxApiDescription.FindStringSubmatch(line)[1]
 			case rxApiTitle.MatchString(line):
@@ -110,18 +188,62 @@ func intermediatateApi(commentBlocks []string) ApiIntermediate {
 					Path: matches[2],
 				}
 				apiIntermediate.SubApis = append(apiIntermediate.SubApis, subApi)
+
+			case rxSecurityDef.MatchString(line):
+				matches := rxSecurityDef.FindStringSubmatch(line)
+				definition, ok := parseSecurityDefinition(matches[1], matches[2], matches[3])
+				if !ok {
+					reportProblem(pos, "unparsed-annotation", "Malformed security definition: %s", strings.TrimSpace(line))
+					continue
+				}
+				apiIntermediate.SecurityDefinitions = append(apiIntermediate.SecurityDefinitions, definition)
+
+			case rxSecurityScope.MatchString(line):
+				matches := rxSecurityScope.FindStringSubmatch(line)
+				if _, ok := scopes[matches[1]]; !ok {
+					scopes[matches[1]] = make(map[string]string)
+					scopesPos[matches[1]] = pos
+				}
+				scopes[matches[1]][matches[2]] = matches[3]
+
+			case rxSecurity.MatchString(line):
+				requirement, ok := parseSecurityRequirement(rxSecurity.FindStringSubmatch(line)[1])
+				if !ok {
+					reportProblem(pos, "unparsed-annotation", "Malformed security requirement: %s", strings.TrimSpace(line))
+					continue
+				}
+				apiIntermediate.Security = appendSecurityRequirement(apiIntermediate.Security, requirement)
+
+			case isAnnotation(line):
+				reportProblem(pos, "unparsed-annotation", "Annotation could not be parsed: %s", strings.TrimSpace(line))
 			}
 		}
 	}
 
+	for scheme, schemeScopes := range scopes {
+		found := false
+		for _, definition := range apiIntermediate.SecurityDefinitions {
+			if definition.Name == scheme && definition.Type == "oauth2" {
+				for scope, description := range schemeScopes {
+					definition.Scopes[scope] = description
+				}
+				found = true
+			}
+		}
+		if !found {
+			reportWarning(scopesPos[scheme], "unknown-security-scheme", "Scopes were declared for an unknown OAuth 2.0 security scheme (%s).", scheme)
+		}
+	}
+
 	return apiIntermediate
 }
 
-func intermediatateOperation(commentBlock string) OperationIntermediate {
+func intermediatateOperation(commentBlock CommentBlock) OperationIntermediate {
 
 	// @Title Get TimeZone
 	// @Description Return a TimeZone, given its id
 	// @Accept  json
+	// @Produce json, csv
 	//
 	// @Param   x-ems-consumer	header	string  true	"Defines the consumer of the API. MobileApp, etc."
 	// @Param   x-ems-api-token	header	string	true	"Auth token, from /authenticate request"
@@ -131,6 +253,9 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 	// @Success 200 {object} model.TimeZoneModel "Success"
 	// @Failure 400 {object} apicommon.ErrorResponse "Bad Request"
 	// @Failure 401 {object} apicommon.ErrorResponse "Invalid or missing consumer credentials"
+	// @ResponseHeader 200 X-Rate-Limit-Remaining int32 "The number of requests left for the time window"
+	// @Security ApiToken
+	// @Security OAuth [read:timezones]
 	// @Router /timezones/{id} [get]
 
 	var (
@@ -138,43 +263,36 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 		// escape sequences. Do not trust IntelliJ.
 		rxAccept      *regexp.Regexp = regexp.MustCompile(`@Accept\s+(.+)`)
 		rxDescription *regexp.Regexp = regexp.MustCompile(`@Description\s+(.+)`)
-		rxParameter   *regexp.Regexp = regexp.MustCompile(`@Param\s+([\w-]+)\s+(\w+)\s+([\w\.]+)\s+(\w+)\s+\"(.+)\"`)
-		rxResponse    *regexp.Regexp = regexp.MustCompile(`@(Success|Failure)\s+(\d+)\s+\{([\w]+)\}\s+([\w\.]+)\s+\"(.+)\"`)
+		rxProduce     *regexp.Regexp = regexp.MustCompile(`@Produce\s+(.+)`)
+		rxParameter   *regexp.Regexp = regexp.MustCompile(`@Param\s+([\w-]+)\s+(\w+)\s+([\w\.]+(?:\[[\w\.\[\], ]+\])?)\s+(\w+)\s+\"(.+)\"`)
+		rxResponse    *regexp.Regexp = regexp.MustCompile(`@(Success|Failure)\s+(\d+)\s+\{([\w]+)\}\s+([\w\.]+(?:\[[\w\.\[\], ]+\])?)\s+\"(.+)\"`)
+		rxHeader      *regexp.Regexp = regexp.MustCompile(`@ResponseHeader\s+(\d+)\s+([\w-]+)\s+([\w\.\*\[\]]+)\s+\"(.+)\"`)
 		rxRouter      *regexp.Regexp = regexp.MustCompile(`@Router\s+([/\w\d-{}]+)\s+\[(\w+)\]`)
+		rxSecurity    *regexp.Regexp = regexp.MustCompile(`@Security\s+(.+)`)
 		rxTitle       *regexp.Regexp = regexp.MustCompile(`@Title\s+(.+)`)
 	)
 
 	var operationIntermediate OperationIntermediate = OperationIntermediate{
 		Accepts:    make([]string, 0),
+		Produces:   make([]string, 0),
 		Parameters: make([]ParameterIntermediate, 0),
 		Responses:  make([]*ResponseIntermediate, 0),
+		Headers:    make([]ResponseHeaderIntermediate, 0),
+		Pos:        commentBlock.Pos,
 	}
 
-	b := bytes.NewBufferString(commentBlock)
-	scanner := bufio.NewScanner(b)
-	for scanner.Scan() {
-		line := scanner.Text()
+	for _, commentLine := range commentBlock.Lines {
+		line := commentLine.Text
+		pos := commentLine.Pos
 
 		switch {
 
 		case rxAccept.MatchString(line):
-
 			raw := rxAccept.FindStringSubmatch(line)[1]
-			accepts := strings.Split(raw, ",")
-			for _, accept := range accepts {
-				accept = strings.TrimSpace(accept)
-				accept = strings.ToLower(accept)
-
-				if accept == "" {
-					continue
-				} else if accept == "json" {
-					accept = "application/json"
-				} else if accept == "xml" {
-					accept = "application/xml"
-				}
-
-				operationIntermediate.Accepts = append(operationIntermediate.Accepts, accept)
-			}
+			operationIntermediate.Accepts = append(operationIntermediate.Accepts, parseMediaTypes(raw, pos)...)
+		case rxProduce.MatchString(line):
+			raw := rxProduce.FindStringSubmatch(line)[1]
+			operationIntermediate.Produces = append(operationIntermediate.Produces, parseMediaTypes(raw, pos)...)
 
 		case rxDescription.MatchString(line):
 			operationIntermediate.Description = rxDescription.FindStringSubmatch(line)[1]
@@ -182,6 +300,11 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 
 			matches := rxParameter.FindStringSubmatch(line)
 
+			required := strings.ToLower(matches[4])
+			if required != "true" && required != "false" {
+				reportProblem(pos, "unparsed-annotation", "Parameter must be either required ('true') or not ('false'): %s", strings.TrimSpace(line))
+			}
+
 			parameterType := &MemberIntermediate{
 				Type:     matches[3],
 				JsonName: matches[1],
@@ -190,8 +313,9 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 			parameterIntermediate := ParameterIntermediate{
 				In:          matches[2],
 				Type:        parameterType,
-				Required:    strings.ToLower(matches[4]) == "true",
+				Required:    required == "true",
 				Description: matches[5],
+				Pos:         pos,
 			}
 
 			operationIntermediate.Parameters = append(operationIntermediate.Parameters, parameterIntermediate)
@@ -232,35 +356,72 @@ func intermediatateOperation(commentBlock string) OperationIntermediate {
 				StatusCode:  statusCode,
 				Type:        responseType,
 				Description: matches[5],
+				Pos:         pos,
 			}
 
 			operationIntermediate.Responses = append(operationIntermediate.Responses, responseIntermediate)
 
+		case rxHeader.MatchString(line):
+
+			matches := rxHeader.FindStringSubmatch(line)
+			statusCode, _ := strconv.Atoi(matches[1])
+
+			headerIntermediate := ResponseHeaderIntermediate{
+				StatusCode:  statusCode,
+				Name:        matches[2],
+				Type:        matches[3],
+				Description: matches[4],
+				Pos:         pos,
+			}
+
+			operationIntermediate.Headers = append(operationIntermediate.Headers, headerIntermediate)
+
 		case rxRouter.MatchString(line):
 			matches := rxRouter.FindStringSubmatch(line)
 			operationIntermediate.Path = matches[1]
 			operationIntermediate.Method = matches[2]
 
+		case rxSecurity.MatchString(line):
+			requirement, ok := parseSecurityRequirement(rxSecurity.FindStringSubmatch(line)[1])
+			if !ok {
+				reportProblem(pos, "unparsed-annotation", "Malformed security requirement: %s", strings.TrimSpace(line))
+				continue
+			}
+			operationIntermediate.Security = appendSecurityRequirement(operationIntermediate.Security, requirement)
+
 		case rxTitle.MatchString(line):
 			operationIntermediate.Title = rxTitle.FindStringSubmatch(line)[1]
 
-		default:
-
-			//log.Print(line)
-
+		case isAnnotation(line):
+			reportProblem(pos, "unparsed-annotation", "Annotation could not be parsed: %s", strings.TrimSpace(line))
 		}
 	}
 
 	return operationIntermediate
 }
 
-func mergeDefinitions(dst, src *DefinitionIntermediate) {
-	for srcName, srcMember := range src.Members {
-		_, exists := dst.Members[srcName]
-		if !exists {
-			dst.Members[srcName] = srcMember
+// Any line that begins with a keyword is meant to be parsed.
+var rxAnnotation *regexp.Regexp = regexp.MustCompile(`^\s*@\w+`)
+
+func isAnnotation(line string) bool {
+	return rxAnnotation.MatchString(line)
+}
+
+/*
+Operations are collected package by package, in no particular order. Sorting
+them by where they were found keeps the document stable from one run to the
+next, and gives meaning to the first of several duplicate operations.
+*/
+func sortOperations(operationIntermediates []OperationIntermediate) {
+	sort.SliceStable(operationIntermediates, func(i, j int) bool {
+		a := fileSet.Position(operationIntermediates[i].Pos)
+		b := fileSet.Position(operationIntermediates[j].Pos)
+
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
 		}
-	}
+		return a.Offset < b.Offset
+	})
 }
 
 func tagOperations(apiIntermediate ApiIntermediate, operationIntermediates []OperationIntermediate) []OperationIntermediate {
@@ -278,3 +439,22 @@ func tagOperations(apiIntermediate ApiIntermediate, operationIntermediates []Ope
 
 	return newOperationIntermediates
 }
+
+/*
+Before the @Produce tag existed, the @Accept tag defined both what an operation
+consumes and what it produces. For the sake of existing annotations, an
+operation that doesn't say what it produces still produces what it accepts,
+unless the API defines what operations produce by default.
+*/
+func inheritProduces(apiIntermediate ApiIntermediate, operationIntermediates []OperationIntermediate) []OperationIntermediate {
+	newOperationIntermediates := make([]OperationIntermediate, 0)
+
+	for _, operationIntermediate := range operationIntermediates {
+		if len(operationIntermediate.Produces) == 0 && len(apiIntermediate.Produces) == 0 {
+			operationIntermediate.Produces = operationIntermediate.Accepts
+		}
+		newOperationIntermediates = append(newOperationIntermediates, operationIntermediate)
+	}
+
+	return newOperationIntermediates
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"github.com/jackmanlabs/errors"
+	"go/token"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+)
+
+/*
+Package resolution is delegated to golang.org/x/tools/go/packages, which in
+turn asks the go command. This means that go.mod (including replace
+directives), vendor/ directories, build tags and GOFLAGS are all respected the
+same way they would be by 'go build', and the application being documented no
+longer needs to live in GOPATH.
+
+The whole import graph of the main package is resolved, parsed and type checked
+with a single call. Packages are then looked up by import path. Because every
+package shares the same file set, any token.Pos can be resolved no matter which
+package it came from.
+
+Having full type information means that we never have to guess which package a
+type name refers to; the type checker has already done that for us.
+*/
+
+const loadMode packages.LoadMode = packages.NeedName |
+	packages.NeedFiles |
+	packages.NeedImports |
+	packages.NeedDeps |
+	packages.NeedModule |
+	packages.NeedTypes |
+	packages.NeedSyntax |
+	packages.NeedTypesInfo
+
+var (
+	fileSet        *token.FileSet               = token.NewFileSet()
+	loadedPackages map[string]*packages.Package = make(map[string]*packages.Package) // map[importPath]package
+)
+
+/*
+Loads the package described by the pattern (an import path or a relative
+directory, such as '.') along with all of its dependencies. The import path of
+the main package is returned.
+*/
+func loadPackages(pattern string) (string, error) {
+
+	cfg := &packages.Config{Mode: loadMode, Fset: fileSet}
+	pkgs, err := packages.Load(cfg, pattern)
+	if err != nil {
+		return "", errors.Stack(err)
+	}
+
+	if len(pkgs) == 0 {
+		return "", errors.New("No package found for pattern: " + pattern)
+	}
+
+	if len(pkgs) > 1 {
+		return "", errors.New("The pattern matches more than one package: " + pattern)
+	}
+
+	root := pkgs[0]
+	if len(root.Errors) > 0 && len(root.GoFiles) == 0 {
+		return "", errors.New(root.Errors[0].Error())
+	}
+
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		loadedPackages[pkg.PkgPath] = pkg
+	})
+
+	return root.PkgPath, nil
+}
+
+/*
+Returns the package with the given import path. Packages that aren't part of the
+import graph of the main package are loaded on demand.
+*/
+func getPackage(pkgPath string) (*packages.Package, error) {
+
+	if pkg, ok := loadedPackages[pkgPath]; ok {
+		return pkg, nil
+	}
+
+	cfg := &packages.Config{Mode: loadMode, Fset: fileSet}
+	pkgs, err := packages.Load(cfg, pkgPath)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	if len(pkgs) != 1 {
+		return nil, errors.New("Could not resolve package: " + pkgPath)
+	}
+
+	// Type objects are only comparable within a single load, so we can't
+	// replace packages that we already know about.
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if _, ok := loadedPackages[pkg.PkgPath]; !ok {
+			loadedPackages[pkg.PkgPath] = pkg
+		}
+	})
+
+	return pkgs[0], nil
+}
+
+/*
+Evaluates a type expression, as written in an annotation, in the scope of the
+file containing the position. Because the file scope is used, import aliases and
+dot-imports are resolved exactly the way the compiler resolves them.
+*/
+func evalTypeExpression(pkgPath string, pos token.Pos, expr string) (types.Type, error) {
+
+	pkg, err := getPackage(pkgPath)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	if pkg.Types == nil {
+		return nil, errors.New("No type information available for package: " + pkgPath)
+	}
+
+	tv, err := types.Eval(fileSet, pkg.Types, pos, expr)
+	if err != nil {
+		return nil, errors.Stack(err)
+	}
+
+	if !tv.IsType() {
+		return nil, errors.Newf("Expression is not a type: %s", expr)
+	}
+
+	return tv.Type, nil
+}
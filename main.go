@@ -1,11 +1,9 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"github.com/go-openapi/spec"
 	"github.com/jackmanlabs/errors"
-	"go/build"
 	"log"
 	"os"
 	"runtime/pprof"
@@ -14,10 +12,20 @@ import (
 
 var (
 	// Command-line parameters
-	pkgPath     *string = flag.String("pkg", "", "The main package of your application.")
-	profilePath *string = flag.String("profile", "", "The path where you'd like to store profiling results.")
-	ignore      *string = flag.String("ignore", "", "The comma seperated package paths that you want to ignore.")
-	naming      *string = flag.String("naming", "full", "One of 'full', 'partial', or 'simple' to describe the amount of the package path on the resulting JSON models.")
+	pkgPath      *string = flag.String("pkg", "", "The main package of your application.")
+	profilePath  *string = flag.String("profile", "", "The path where you'd like to store profiling results.")
+	ignore       *string = flag.String("ignore", "", "The comma seperated package paths that you want to ignore.")
+	naming       *string = flag.String("naming", "full", "One of 'full', 'partial', or 'simple' to describe the amount of the package path on the resulting JSON models.")
+	openapi      *string = flag.String("openapi", "2.0", "The version of the specification to generate, one of '2.0' (Swagger), '3.0', or '3.1'.")
+	format       *string = flag.String("format", "json", "The format of the generated document, either 'json' or 'yaml'.")
+	duplicates   *string = flag.String("duplicates", "keep-first", "What to do with routes defined more than once for the same method and path: 'error', 'keep-first', or 'merge' (their responses).")
+	embedding    *string = flag.String("embedding", "flatten", "How embedded structs are described: 'flatten' (their fields are promoted, as encoding/json does) or 'allOf' (by reference to their own models).")
+	disambiguate *bool   = flag.Bool("disambiguate", false, "Rename models whose names collide under the 'partial' or 'simple' naming conventions after the shortest unique suffix of their package path.")
+	strict       *bool   = flag.Bool("strict", false, "Fail, rather than warn, when annotations can't be parsed or types can't be resolved.")
+	outPath      *string = flag.String("out", "", "The path of the file where the generated document is written. By default, it's printed to stdout.")
+	configPath   *string = flag.String("config", "", "The path of a YAML file that maps third-party Go types to the schemas that describe them.")
+
+	diagnosticsFormat *string = flag.String("diagnostics-format", "text", "The format of the diagnostics printed to stderr, either 'text' or 'json' (one object per line).")
 )
 
 var (
@@ -26,10 +34,8 @@ var (
 	// were to pass these three things around, it would get very tedious very
 	// fast. This is not a multi-threaded program, and we've been careful to
 	// avoid modifying maps during iterations.
-	definitionStore DefinitionStore        = make(map[string]*DefinitionIntermediate)
-	pkgInfos        map[string]PackageInfo = make(map[string]PackageInfo)
-	srcPath         string
-	ignoredPackages []string = make([]string, 0)
+	definitionStore DefinitionStore = make(map[string]*DefinitionIntermediate)
+	ignoredPackages []string        = make([]string, 0)
 )
 
 func main() {
@@ -54,6 +60,38 @@ func main() {
 		log.Fatal("Unrecognized value provided for naming convention: " + *naming)
 	}
 
+	if !(*openapi == "2.0" || *openapi == "3.0" || *openapi == "3.1") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for OpenAPI version: " + *openapi)
+	}
+
+	if !(*format == "json" || *format == "yaml") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for document format: " + *format)
+	}
+
+	if !(*duplicates == "error" || *duplicates == "keep-first" || *duplicates == "merge") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for duplicate policy: " + *duplicates)
+	}
+
+	if !(*embedding == "flatten" || *embedding == "allOf") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for embedding: " + *embedding)
+	}
+
+	if !(*diagnosticsFormat == "text" || *diagnosticsFormat == "json") {
+		flag.Usage()
+		log.Fatal("Unrecognized value provided for diagnostics format: " + *diagnosticsFormat)
+	}
+
+	if *configPath != "" {
+		err := loadConfig(*configPath)
+		if err != nil {
+			log.Fatal(errors.Stack(err))
+		}
+	}
+
 	ignores := strings.Split(*ignore, ",")
 	for _, i := range ignores {
 		if i != "" {
@@ -61,24 +99,19 @@ func main() {
 		}
 	}
 
-	var err error
-
-	// Determine the source path of the package specified.
-	srcPath, err = getPackageSourceDir(*pkgPath)
+	// Resolve the package specified, along with everything it imports.
+	rootPkgPath, err := loadPackages(*pkgPath)
 	if err != nil {
 		log.Fatal(errors.Stack(err))
 	}
 
-	// Which packages need to be analyzed? Get a list of all pkgInfos.
-	pkgInfos, err = getPackageInfoRecursive(*pkgPath)
-	if err != nil {
-		log.Fatal(errors.Stack(err))
-	}
+	// Which packages need to be analyzed? Get a list of all import paths.
+	importPaths := getImportPathsRecursive(rootPkgPath)
 
 	// What pkgComments need to be parsed?
 	// Find all pkgComments with keywords.
-	pkgComments := make(map[string][]string, 0)
-	for importPath := range pkgInfos {
+	pkgComments := make(map[string][]CommentBlock, 0)
+	for _, importPath := range importPaths {
 		newBlocks, err := getRelevantComments(importPath)
 		if err != nil {
 			log.Fatal(errors.Stack(err))
@@ -89,7 +122,7 @@ func main() {
 
 	// Now, we need to organize the pkgComments and parse them.
 
-	apiComments := make([]string, 0)
+	apiComments := make([]CommentBlock, 0)
 	for _, commentBlocks := range pkgComments {
 		newApiComments := extractApiComments(commentBlocks)
 		apiComments = append(apiComments, newApiComments...)
@@ -97,7 +130,7 @@ func main() {
 	apiIntermediate := intermediatateApi(apiComments)
 
 	// We need to know the package so we know where to look for the types.
-	operationPkgComments := make(map[string][]string)
+	operationPkgComments := make(map[string][]CommentBlock)
 	for importPath, comments := range pkgComments {
 		operationPkgComments[importPath] = extractOperationComments(comments)
 	}
@@ -111,13 +144,18 @@ func main() {
 		}
 	}
 
+	sortOperations(operationIntermediates)
 	operationIntermediates = tagOperations(apiIntermediate, operationIntermediates)
+	operationIntermediates = inheritProduces(apiIntermediate, operationIntermediates)
+	checkSecurityRequirements(apiIntermediate, operationIntermediates)
 
 	err = deriveDefinitionsFromOperations(operationIntermediates)
 	if err != nil {
 		log.Fatal(errors.Stack(err))
 	}
 
+	definitionStore.ResolveCollisions(*disambiguate)
+
 	// Transform the extractions above and combine them into a single Swagger Spec.
 
 	swagger := swaggerizeApi(apiIntermediate)
@@ -130,32 +168,30 @@ func main() {
 
 	swagger.Definitions = definitions
 
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "\t")
-	err = enc.Encode(swagger)
-	if err != nil {
-		log.Fatal(errors.Stack(err))
+	var document interface{} = swagger
+	switch *openapi {
+	case "3.0":
+		document = openapizeSwagger(swagger, "3.0.3")
+	case "3.1":
+		document = openapizeSwagger(swagger, "3.1.0")
 	}
-}
 
-func getPackageSourceDir(pkgPath string) (string, error) {
+	// Leave any existing document alone if there were errors.
+	if n := countErrors(); n > 0 {
+		log.Fatalf("%d error(s) found.", n)
+	}
 
-	var (
-		bpkg *build.Package
-		err  error
-	)
+	output, err := encodeDocument(document, *format)
+	if err != nil {
+		log.Fatal(errors.Stack(err))
+	}
 
-	// I should hope there's an easier way of resolving the source path.
-	srcDirs := build.Default.SrcDirs()
-	for _, srcDir := range srcDirs {
-		bpkg, err = build.Import(pkgPath, srcDir, 0)
-		if err == nil {
-			break
-		}
+	if *outPath == "" {
+		_, err = os.Stdout.Write(output)
+	} else {
+		err = writeFileAtomic(*outPath, output)
 	}
 	if err != nil {
-		return "", errors.Stack(err)
+		log.Fatal(errors.Stack(err))
 	}
-
-	return bpkg.Dir, nil
 }
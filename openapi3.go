@@ -0,0 +1,524 @@
+package main
+
+import (
+	"github.com/go-openapi/spec"
+	"sort"
+	"strconv"
+)
+
+/*
+The go-openapi toolkit only models Swagger 2.0 documents. Rather than teaching
+every Intermediate how to describe itself twice, the Swagger 2.0 document is
+always generated first, then converted to OpenAPI 3.x when requested. The
+schemas themselves are mostly shared by all versions; the differences (the
+reference paths, and the JSON Schema 2020-12 keywords used by OpenAPI 3.1) are
+taken care of when the schemas are generated, with the helpers below.
+
+See the following for reference:
+	https://github.com/OAI/OpenAPI-Specification/blob/master/versions/3.0.3.md
+	https://github.com/OAI/OpenAPI-Specification/blob/master/versions/3.1.0.md
+*/
+
+type OpenApi3Document struct {
+	OpenApi    string                      `json:"openapi"`
+	Info       *spec.Info                  `json:"info"`
+	Servers    []OpenApi3Server            `json:"servers,omitempty"`
+	Paths      map[string]OpenApi3PathItem `json:"paths"`
+	Components *OpenApi3Components         `json:"components,omitempty"`
+	Security   []map[string][]string       `json:"security,omitempty"`
+}
+
+type OpenApi3Server struct {
+	Url         string `json:"url"`
+	Description string `json:"description,omitempty"`
+}
+
+type OpenApi3Components struct {
+	Schemas         map[string]spec.Schema            `json:"schemas,omitempty"`
+	SecuritySchemes map[string]OpenApi3SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenApi3SecurityScheme struct {
+	Type        string              `json:"type"`
+	Description string              `json:"description,omitempty"`
+	Name        string              `json:"name,omitempty"`
+	In          string              `json:"in,omitempty"`
+	Scheme      string              `json:"scheme,omitempty"`
+	Flows       *OpenApi3OAuthFlows `json:"flows,omitempty"`
+}
+
+type OpenApi3OAuthFlows struct {
+	Implicit          *OpenApi3OAuthFlow `json:"implicit,omitempty"`
+	Password          *OpenApi3OAuthFlow `json:"password,omitempty"`
+	ClientCredentials *OpenApi3OAuthFlow `json:"clientCredentials,omitempty"`
+	AuthorizationCode *OpenApi3OAuthFlow `json:"authorizationCode,omitempty"`
+}
+
+type OpenApi3OAuthFlow struct {
+	AuthorizationUrl string            `json:"authorizationUrl,omitempty"`
+	TokenUrl         string            `json:"tokenUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+// map[method]operation
+type OpenApi3PathItem map[string]*OpenApi3Operation
+
+type OpenApi3Operation struct {
+	Tags        []string                    `json:"tags,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	OperationId string                      `json:"operationId,omitempty"`
+	Parameters  []OpenApi3Parameter         `json:"parameters,omitempty"`
+	RequestBody *OpenApi3RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]OpenApi3Response `json:"responses"`
+	Deprecated  bool                        `json:"deprecated,omitempty"`
+
+	// A pointer, so that an empty list (no security at all) can be told apart
+	// from a missing one (the default security of the API).
+	Security *[]map[string][]string `json:"security,omitempty"`
+}
+
+type OpenApi3Parameter struct {
+	Name        string       `json:"name"`
+	In          string       `json:"in"`
+	Description string       `json:"description,omitempty"`
+	Required    bool         `json:"required,omitempty"`
+	Schema      *spec.Schema `json:"schema,omitempty"`
+}
+
+type OpenApi3RequestBody struct {
+	Description string                       `json:"description,omitempty"`
+	Required    bool                         `json:"required,omitempty"`
+	Content     map[string]OpenApi3MediaType `json:"content"`
+}
+
+type OpenApi3Response struct {
+	Description string                       `json:"description"`
+	Headers     map[string]OpenApi3Header    `json:"headers,omitempty"`
+	Content     map[string]OpenApi3MediaType `json:"content,omitempty"`
+}
+
+type OpenApi3Header struct {
+	Description string       `json:"description,omitempty"`
+	Schema      *spec.Schema `json:"schema,omitempty"`
+}
+
+type OpenApi3MediaType struct {
+	Schema *spec.Schema `json:"schema,omitempty"`
+}
+
+// The JSON pointer prefix of the section of the document where definitions live.
+func definitionsPath() string {
+	if *openapi == "2.0" {
+		return "#/definitions/"
+	}
+	return "#/components/schemas/"
+}
+
+func openapizeSwagger(swagger *spec.Swagger, version string) *OpenApi3Document {
+
+	document := &OpenApi3Document{
+		OpenApi: version,
+		Info:    swagger.Info,
+		Paths:   make(map[string]OpenApi3PathItem),
+	}
+
+	// A relative server URL is resolved against the location of the document,
+	// which is exactly how the Swagger 2.0 base path behaves without a host.
+	if swagger.BasePath != "" {
+		document.Servers = []OpenApi3Server{{Url: swagger.BasePath}}
+	}
+
+	if len(swagger.Definitions) > 0 || len(swagger.SecurityDefinitions) > 0 {
+		document.Components = &OpenApi3Components{
+			Schemas: swagger.Definitions,
+		}
+	}
+
+	if len(swagger.SecurityDefinitions) > 0 {
+		document.Components.SecuritySchemes = make(map[string]OpenApi3SecurityScheme)
+		for name, scheme := range swagger.SecurityDefinitions {
+			document.Components.SecuritySchemes[name] = openapizeSecurityScheme(scheme)
+		}
+	}
+
+	document.Security = swagger.Security
+
+	if swagger.Paths == nil {
+		return document
+	}
+
+	for path, pathItem := range swagger.Paths.Paths {
+		operations := map[string]*spec.Operation{
+			"get":     pathItem.Get,
+			"put":     pathItem.Put,
+			"post":    pathItem.Post,
+			"delete":  pathItem.Delete,
+			"options": pathItem.Options,
+			"head":    pathItem.Head,
+			"patch":   pathItem.Patch,
+		}
+
+		openApiPathItem := make(OpenApi3PathItem)
+		for method, operation := range operations {
+			if operation != nil {
+				openApiPathItem[method] = openapizeOperation(swagger, operation)
+			}
+		}
+
+		document.Paths[path] = openApiPathItem
+	}
+
+	return document
+}
+
+func openapizeOperation(swagger *spec.Swagger, operation *spec.Operation) *OpenApi3Operation {
+
+	openApiOperation := &OpenApi3Operation{
+		Tags:        operation.Tags,
+		Summary:     operation.Summary,
+		Description: operation.Description,
+		OperationId: operation.ID,
+		Deprecated:  operation.Deprecated,
+		Responses:   make(map[string]OpenApi3Response),
+	}
+
+	if operation.Security != nil {
+		security := operation.Security
+		openApiOperation.Security = &security
+	}
+
+	consumes := operation.Consumes
+	if len(consumes) == 0 {
+		consumes = swagger.Consumes
+	}
+	if len(consumes) == 0 {
+		consumes = []string{"application/json"}
+	}
+
+	produces := operation.Produces
+	if len(produces) == 0 {
+		produces = swagger.Produces
+	}
+	if len(produces) == 0 {
+		produces = []string{"application/json"}
+	}
+
+	var formParameters []spec.Parameter
+
+	for _, parameter := range operation.Parameters {
+		switch parameter.In {
+		case "body":
+			requestBody := &OpenApi3RequestBody{
+				Description: parameter.Description,
+				Required:    parameter.Required,
+				Content:     make(map[string]OpenApi3MediaType),
+			}
+
+			schema := parameter.Schema
+			if schema != nil {
+				// The title was only ever the name of the body parameter.
+				untitled := *schema
+				untitled.Title = ""
+				schema = &untitled
+			}
+
+			for _, mediaType := range consumes {
+				requestBody.Content[mediaType] = OpenApi3MediaType{Schema: schema}
+			}
+
+			openApiOperation.RequestBody = requestBody
+
+		case "formData":
+			formParameters = append(formParameters, parameter)
+
+		default:
+			openApiParameter := OpenApi3Parameter{
+				Name:        parameter.Name,
+				In:          parameter.In,
+				Description: parameter.Description,
+				Required:    parameter.Required,
+				Schema:      simpleSchema(parameter.SimpleSchema, parameter.CommonValidations),
+			}
+
+			// Path parameters are always required in OpenAPI 3.0.
+			if parameter.In == "path" {
+				openApiParameter.Required = true
+			}
+
+			openApiOperation.Parameters = append(openApiOperation.Parameters, openApiParameter)
+		}
+	}
+
+	if len(formParameters) > 0 {
+		openApiOperation.RequestBody = openapizeFormParameters(formParameters, consumes)
+	}
+
+	if operation.Responses != nil {
+		for statusCode, response := range operation.Responses.StatusCodeResponses {
+			openApiOperation.Responses[strconv.Itoa(statusCode)] = openapizeResponse(response, produces)
+		}
+
+		if operation.Responses.Default != nil {
+			openApiOperation.Responses["default"] = openapizeResponse(*operation.Responses.Default, produces)
+		}
+	}
+
+	return openApiOperation
+}
+
+/*
+Basic authentication is one of several HTTP authentication schemes in OpenAPI
+3.0, and each OAuth 2.0 flow has been renamed:
+
+	application -> clientCredentials
+	accessCode  -> authorizationCode
+*/
+func openapizeSecurityScheme(scheme *spec.SecurityScheme) OpenApi3SecurityScheme {
+
+	openApiScheme := OpenApi3SecurityScheme{
+		Type:        scheme.Type,
+		Description: scheme.Description,
+	}
+
+	switch scheme.Type {
+	case "basic":
+		openApiScheme.Type = "http"
+		openApiScheme.Scheme = "basic"
+
+	case "apiKey":
+		openApiScheme.Name = scheme.Name
+		openApiScheme.In = scheme.In
+
+	case "oauth2":
+		flow := &OpenApi3OAuthFlow{
+			AuthorizationUrl: scheme.AuthorizationURL,
+			TokenUrl:         scheme.TokenURL,
+			Scopes:           scheme.Scopes,
+		}
+
+		// The scopes are required, even if there aren't any.
+		if flow.Scopes == nil {
+			flow.Scopes = make(map[string]string)
+		}
+
+		openApiScheme.Flows = new(OpenApi3OAuthFlows)
+		switch scheme.Flow {
+		case "implicit":
+			openApiScheme.Flows.Implicit = flow
+		case "password":
+			openApiScheme.Flows.Password = flow
+		case "application":
+			openApiScheme.Flows.ClientCredentials = flow
+		case "accessCode":
+			openApiScheme.Flows.AuthorizationCode = flow
+		}
+	}
+
+	return openApiScheme
+}
+
+/*
+Swagger 2.0 form parameters are separate parameters. In OpenAPI 3.0, they're
+the properties of an object schema describing the request body.
+*/
+func openapizeFormParameters(parameters []spec.Parameter, consumes []string) *OpenApi3RequestBody {
+
+	schema := new(spec.Schema)
+	schema.Typed("object", "")
+	schema.Properties = make(map[string]spec.Schema)
+
+	hasFile := false
+	for _, parameter := range parameters {
+		property := simpleSchema(parameter.SimpleSchema, parameter.CommonValidations)
+		property.Description = parameter.Description
+
+		if parameter.Type == "file" {
+			hasFile = true
+			property.Typed("string", "binary")
+		}
+
+		schema.Properties[parameter.Name] = *property
+
+		if parameter.Required {
+			schema.Required = append(schema.Required, parameter.Name)
+		}
+	}
+	sort.Strings(schema.Required)
+
+	mediaTypes := make([]string, 0)
+	for _, mediaType := range consumes {
+		if mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+
+	if len(mediaTypes) == 0 {
+		if hasFile {
+			mediaTypes = append(mediaTypes, "multipart/form-data")
+		} else {
+			mediaTypes = append(mediaTypes, "application/x-www-form-urlencoded")
+		}
+	}
+
+	requestBody := &OpenApi3RequestBody{
+		Required: len(schema.Required) > 0,
+		Content:  make(map[string]OpenApi3MediaType),
+	}
+
+	for _, mediaType := range mediaTypes {
+		requestBody.Content[mediaType] = OpenApi3MediaType{Schema: schema}
+	}
+
+	return requestBody
+}
+
+func openapizeResponse(response spec.Response, produces []string) OpenApi3Response {
+
+	openApiResponse := OpenApi3Response{
+		Description: response.Description,
+	}
+
+	if response.Schema != nil {
+		openApiResponse.Content = make(map[string]OpenApi3MediaType)
+		for _, mediaType := range produces {
+			openApiResponse.Content[mediaType] = OpenApi3MediaType{Schema: response.Schema}
+		}
+	}
+
+	if len(response.Headers) > 0 {
+		openApiResponse.Headers = make(map[string]OpenApi3Header)
+		for name, header := range response.Headers {
+			openApiResponse.Headers[name] = OpenApi3Header{
+				Description: header.Description,
+				Schema:      simpleSchema(header.SimpleSchema, header.CommonValidations),
+			}
+		}
+	}
+
+	return openApiResponse
+}
+
+/*
+Swagger 2.0 describes non-body parameters and headers with a subset of the
+schema properties. OpenAPI 3.0 uses a proper schema instead.
+*/
+func simpleSchema(simple spec.SimpleSchema, validations spec.CommonValidations) *spec.Schema {
+
+	schema := new(spec.Schema)
+	if simple.Type != "" {
+		schema.Typed(simple.Type, simple.Format)
+	}
+
+	if simple.Items != nil {
+		schema.Items = &spec.SchemaOrArray{
+			Schema: simpleSchema(simple.Items.SimpleSchema, simple.Items.CommonValidations),
+		}
+	}
+
+	schema.Default = simple.Default
+
+	if validations.Maximum != nil {
+		if validations.ExclusiveMaximum {
+			withExclusiveMaximum(schema, *validations.Maximum)
+		} else {
+			schema.WithMaximum(*validations.Maximum, false)
+		}
+	}
+
+	if validations.Minimum != nil {
+		if validations.ExclusiveMinimum {
+			withExclusiveMinimum(schema, *validations.Minimum)
+		} else {
+			schema.WithMinimum(*validations.Minimum, false)
+		}
+	}
+
+	schema.MaxLength = validations.MaxLength
+	schema.MinLength = validations.MinLength
+	schema.Pattern = validations.Pattern
+	schema.MaxItems = validations.MaxItems
+	schema.MinItems = validations.MinItems
+	schema.UniqueItems = validations.UniqueItems
+	schema.Enum = validations.Enum
+
+	return schema
+}
+
+// Are schemas described with JSON Schema 2020-12, as they are in OpenAPI 3.1?
+func isJsonSchema2020() bool {
+	return *openapi == "3.1"
+}
+
+/*
+Up to JSON Schema draft 4 (Swagger 2.0 and OpenAPI 3.0), exclusive bounds are
+booleans that modify 'minimum' and 'maximum'. Since then, they're numbers in
+their own right.
+*/
+func withExclusiveMinimum(schema *spec.Schema, min float64) {
+
+	if !isJsonSchema2020() {
+		schema.WithMinimum(min, true)
+		return
+	}
+
+	schema.Minimum = nil
+	schema.ExclusiveMinimum = false
+	withExtraProp(schema, "exclusiveMinimum", min)
+}
+
+func withExclusiveMaximum(schema *spec.Schema, max float64) {
+
+	if !isJsonSchema2020() {
+		schema.WithMaximum(max, true)
+		return
+	}
+
+	schema.Maximum = nil
+	schema.ExclusiveMaximum = false
+	withExtraProp(schema, "exclusiveMaximum", max)
+}
+
+/*
+Makes the schema accept null as well. This is only possible with JSON Schema
+2020-12, where 'null' is a type like any other. Otherwise, the schema is
+returned as it is.
+
+A reference can't be combined with a type, so references are wrapped in a
+'oneOf' instead.
+*/
+func nullableSchema(schema *spec.Schema) *spec.Schema {
+
+	if !isJsonSchema2020() {
+		return schema
+	}
+
+	if schema.Ref.String() == "" {
+		schema.AddType("null", "")
+		return schema
+	}
+
+	nullable := new(spec.Schema)
+	nullable.Title = schema.Title
+	nullable.Description = schema.Description
+
+	ref := spec.Schema{}
+	ref.Ref = schema.Ref
+
+	null := spec.Schema{}
+	null.Typed("null", "")
+
+	nullable.OneOf = []spec.Schema{ref, null}
+
+	return nullable
+}
+
+// The schema type has no field for some of the newer keywords, such as 'const'.
+func withExtraProp(schema *spec.Schema, key string, value interface{}) {
+
+	if schema.ExtraProps == nil {
+		schema.ExtraProps = make(map[string]interface{})
+	}
+
+	schema.ExtraProps[key] = value
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/jackmanlabs/errors"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+/*
+Serializes the generated document in the requested format, either 'json' or
+'yaml'.
+
+The spec models only know how to marshal themselves to JSON. Since JSON is a
+subset of YAML, the YAML document is created by parsing the JSON document as
+YAML (which preserves the order of the keys) and writing it out again in block
+style.
+*/
+func encodeDocument(document interface{}, format string) ([]byte, error) {
+
+	b := new(bytes.Buffer)
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(b)
+		enc.SetIndent("", "\t")
+		err := enc.Encode(document)
+		if err != nil {
+			return nil, errors.Stack(err)
+		}
+
+	case "yaml":
+		j, err := json.Marshal(document)
+		if err != nil {
+			return nil, errors.Stack(err)
+		}
+
+		var node yaml.Node
+		err = yaml.Unmarshal(j, &node)
+		if err != nil {
+			return nil, errors.Stack(err)
+		}
+
+		clearNodeStyle(&node)
+
+		enc := yaml.NewEncoder(b)
+		enc.SetIndent(2)
+		err = enc.Encode(&node)
+		if err != nil {
+			return nil, errors.Stack(err)
+		}
+
+		err = enc.Close()
+		if err != nil {
+			return nil, errors.Stack(err)
+		}
+
+	default:
+		return nil, errors.New("Unrecognized document format: " + format)
+	}
+
+	return b.Bytes(), nil
+}
+
+/*
+Nodes parsed from JSON remember that they were written in flow style, with
+quoted strings. Clearing the style lets the encoder pick the most readable
+style, only quoting strings where it's necessary.
+*/
+func clearNodeStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearNodeStyle(child)
+	}
+}
+
+/*
+Writes the data to a temporary file in the same directory, then renames it over
+the destination. The rename is atomic, so a failure at any point leaves the
+existing file untouched, rather than truncated.
+*/
+func writeFileAtomic(path string, data []byte) error {
+
+	var mode os.FileMode = 0644
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+
+	f, err := os.CreateTemp(dir, "."+base+".*.tmp")
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	// If anything goes wrong, don't leave the temporary file behind.
+	tmpPath := f.Name()
+	renamed := false
+	defer func() {
+		if !renamed {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return errors.Stack(err)
+	}
+
+	err = f.Sync()
+	if err != nil {
+		f.Close()
+		return errors.Stack(err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	err = os.Chmod(tmpPath, mode)
+	if err != nil {
+		return errors.Stack(err)
+	}
+
+	err = os.Rename(tmpPath, path)
+	if err != nil {
+		return errors.Stack(err)
+	}
+	renamed = true
+
+	return nil
+}
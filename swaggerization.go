@@ -2,7 +2,9 @@ package main
 
 import (
 	"github.com/go-openapi/spec"
-	"log"
+	"go/token"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -21,11 +23,22 @@ func swaggerizeApi(intermediate ApiIntermediate) *spec.Swagger {
 		// This is ugly, but apparently you can't do direct assignment on embedded members.
 		SwaggerProps: spec.SwaggerProps{
 			BasePath: intermediate.BasePath,
+			Consumes: intermediate.Accepts,
+			Produces: intermediate.Produces,
 			Info:     info,
 			Swagger:  "2.0",
 		},
 	}
 
+	if len(intermediate.SecurityDefinitions) > 0 {
+		swagger.SecurityDefinitions = make(spec.SecurityDefinitions)
+		for _, definition := range intermediate.SecurityDefinitions {
+			swagger.SecurityDefinitions[definition.Name] = definition.SecurityScheme()
+		}
+	}
+
+	swagger.Security = swaggerizeSecurityRequirements(intermediate.Security)
+
 	//for _, subApi := range intermediate.SubApis{
 	//	swagger.Paths.Paths[subApi.Path] = spec.PathItem{}
 	//}
@@ -42,9 +55,26 @@ See the following for reference:
 func swaggerizeOperations(intermediates []OperationIntermediate) map[string]spec.PathItem {
 	pathItems := make(map[string]spec.PathItem)
 
+	// Where each operation was first defined, to report duplicates. Paths that
+	// only differ by the names of their parameters are the same route.
+	// map[method path template]position
+	definedAt := make(map[string]token.Pos)
+
+	// The path that each path template was first written as, which the
+	// operations of the route are documented under.
+	// map[path template]path
+	templatePaths := make(map[string]string)
+
 	for _, operationIntermediate := range intermediates {
 
-		pathItem, ok := pathItems[operationIntermediate.Path]
+		template := pathTemplate(operationIntermediate.Path)
+
+		path, ok := templatePaths[template]
+		if !ok {
+			path = operationIntermediate.Path
+		}
+
+		pathItem, ok := pathItems[path]
 		if !ok {
 			pathItem = spec.PathItem{}
 		}
@@ -54,8 +84,9 @@ func swaggerizeOperations(intermediates []OperationIntermediate) map[string]spec
 				Summary:     operationIntermediate.Title,
 				Description: operationIntermediate.Description,
 				Consumes:    operationIntermediate.Accepts,
-				Produces:    operationIntermediate.Accepts,
+				Produces:    operationIntermediate.Produces,
 				Tags:        []string{operationIntermediate.Tag},
+				Security:    swaggerizeSecurityRequirements(operationIntermediate.Security),
 			},
 		}
 
@@ -63,9 +94,23 @@ func swaggerizeOperations(intermediates []OperationIntermediate) map[string]spec
 			response := new(spec.Response)
 			response.Description = responseIntermediate.Description
 			response.Schema = responseIntermediate.Schema()
+
+			for _, headerIntermediate := range operationIntermediate.Headers {
+				if headerIntermediate.StatusCode == responseIntermediate.StatusCode {
+					response.AddHeader(headerIntermediate.Name, headerIntermediate.Header())
+				}
+			}
+
 			operationObject.RespondsWith(responseIntermediate.StatusCode, response)
 		}
 
+		for _, headerIntermediate := range operationIntermediate.Headers {
+			if !hasStatusCode(operationObject.Responses, headerIntermediate.StatusCode) {
+				reportWarning(headerIntermediate.Pos, "unmatched-response-header", "Response header (%s) was defined for a response that doesn't exist (%d).",
+					headerIntermediate.Name, headerIntermediate.StatusCode)
+			}
+		}
+
 		for _, parameterIntermediate := range operationIntermediate.Parameters {
 			parameter := new(spec.Parameter)
 			parameter.Name = parameterIntermediate.Type.JsonName
@@ -75,46 +120,154 @@ func swaggerizeOperations(intermediates []OperationIntermediate) map[string]spec
 
 			if parameterIntermediate.In == "body" {
 				parameter.Schema = parameterIntermediate.Schema()
+			} else if parameterIntermediate.IsFile() {
+				parameter.Type = "file"
 			} else {
 				isPrimitive, t, _ := IsPrimitive(parameterIntermediate.Type.Type)
+				if mapping, isMapped := mappedType(parameterIntermediate.Type.Object); isMapped {
+					isPrimitive, t = true, mapping.Type
+					parameter.Format = mapping.Format
+					parameter.Pattern = mapping.Pattern
+				}
 				parameter.Type = t
 				if !isPrimitive {
-					log.Print("WARNING: It appears there is non-primitive response parameter someplace other than the request body:" + parameterIntermediate.Type.CanonicalName())
+					reportWarning(parameterIntermediate.Pos, "non-primitive-parameter", "It appears there is non-primitive response parameter someplace other than the request body: %s", parameterIntermediate.Type.CanonicalName())
 				}
 			}
 
 			operationObject.AddParam(parameter)
 		}
 
-		switch strings.ToLower(operationIntermediate.Method) {
-		case "put":
-			pathItem.Put = operationObject
-		case "get":
-			pathItem.Get = operationObject
-		case "post":
-			pathItem.Post = operationObject
-		case "delete":
-			pathItem.Delete = operationObject
-		case "options":
-			pathItem.Options = operationObject
-		case "head":
-			pathItem.Head = operationObject
-		case "patch":
-			pathItem.Patch = operationObject
+		slot := operationSlot(&pathItem, operationIntermediate.Method)
+		if slot == nil {
+			reportProblem(operationIntermediate.Pos, "unknown-method", "Unrecognized HTTP method (%s) for route: %s", operationIntermediate.Method, operationIntermediate.Path)
+			continue
+		}
+
+		route := strings.ToUpper(operationIntermediate.Method) + " " + operationIntermediate.Path
+		routeKey := strings.ToUpper(operationIntermediate.Method) + " " + template
+
+		if *slot != nil {
+			first := formatPos(definedAt[routeKey])
+
+			switch *duplicates {
+			case "error":
+				reportError(operationIntermediate.Pos, "duplicate-operation", "Route (%s) was already defined at %s.", route, first)
+			case "keep-first":
+				reportProblem(operationIntermediate.Pos, "duplicate-operation", "Route (%s) was already defined at %s; this definition is ignored.", route, first)
+			case "merge":
+				reportInfo(operationIntermediate.Pos, "duplicate-operation", "Route (%s) was already defined at %s; the responses are merged.", route, first)
+				for _, statusCode := range mergeResponses(*slot, operationObject) {
+					reportProblem(operationIntermediate.Pos, "duplicate-response", "Response (%d) of route (%s) was already defined at %s; this definition is ignored.", statusCode, route, first)
+				}
+			}
+
+			continue
+		}
+
+		if path != operationIntermediate.Path {
+			reportProblem(operationIntermediate.Pos, "inconsistent-path", "Route (%s) is documented under %s, since the paths only differ by the names of their parameters, which should be the same.", route, path)
 		}
 
-		pathItems[operationIntermediate.Path] = pathItem
+		definedAt[routeKey] = operationIntermediate.Pos
+		templatePaths[template] = path
+		*slot = operationObject
+
+		pathItems[path] = pathItem
 	}
 
 	return pathItems
 }
 
+// Returns the path with the names of its parameters left out, so that paths
+// such as '/things/{id}' and '/things/{thingId}' can be recognized as the same.
+func pathTemplate(path string) string {
+	return rxPathParameter.ReplaceAllString(path, "{}")
+}
+
+var rxPathParameter *regexp.Regexp = regexp.MustCompile(`\{[^}]+\}`)
+
+// Returns the field of the path item that holds the operation for the HTTP
+// method, or nil if the method isn't one that Swagger knows about.
+func operationSlot(pathItem *spec.PathItem, method string) **spec.Operation {
+	switch strings.ToLower(method) {
+	case "put":
+		return &pathItem.Put
+	case "get":
+		return &pathItem.Get
+	case "post":
+		return &pathItem.Post
+	case "delete":
+		return &pathItem.Delete
+	case "options":
+		return &pathItem.Options
+	case "head":
+		return &pathItem.Head
+	case "patch":
+		return &pathItem.Patch
+	}
+
+	return nil
+}
+
+/*
+Adds the responses of the source operation to the destination operation. Where
+both operations define a response for the same status code, the destination's
+response is kept, and the status code is returned.
+*/
+func mergeResponses(dst, src *spec.Operation) []int {
+
+	conflicts := make([]int, 0)
+
+	if src.Responses == nil {
+		return conflicts
+	}
+
+	statusCodes := make([]int, 0)
+	for statusCode := range src.Responses.StatusCodeResponses {
+		statusCodes = append(statusCodes, statusCode)
+	}
+	sort.Ints(statusCodes)
+
+	for _, statusCode := range statusCodes {
+		if hasStatusCode(dst.Responses, statusCode) {
+			conflicts = append(conflicts, statusCode)
+			continue
+		}
+
+		response := src.Responses.StatusCodeResponses[statusCode]
+		dst.RespondsWith(statusCode, &response)
+	}
+
+	if src.Responses.Default != nil && (dst.Responses == nil || dst.Responses.Default == nil) {
+		dst.WithDefaultResponse(src.Responses.Default)
+	}
+
+	return conflicts
+}
+
+func hasStatusCode(responses *spec.Responses, statusCode int) bool {
+	if responses == nil {
+		return false
+	}
+	_, ok := responses.StatusCodeResponses[statusCode]
+	return ok
+}
+
 func swaggerizeDefinitions() map[string]spec.Schema {
 
 	schemas := make(map[string]spec.Schema)
 
-	for _, definition := range definitionStore {
+	for _, key := range definitionStore.Keys() {
+		definition := definitionStore[key]
 		swaggerName := definition.SwaggerName()
+
+		// Of the models that share a name, the first one is documented, as
+		// reported by ResolveCollisions.
+		if _, ok := schemas[swaggerName]; ok {
+			continue
+		}
+
 		schemas[swaggerName] = definition.Schema()
 	}
 
@@ -1,7 +1,7 @@
 package main
 
 import (
-	"regexp"
+	"go/token"
 	"strings"
 )
 
@@ -37,7 +37,8 @@ func IsPrimitive(goType string) (bool, string, string) {
 	case "bool":
 		return true, "boolean", ""
 	case "byte":
-		return true, "string", "byte"
+		// encoding/json writes single bytes (and arrays of them) as numbers.
+		return true, "integer", ""
 	case "complex64":
 		return true, "string", ""
 	case "complex128":
@@ -76,22 +77,6 @@ func IsPrimitive(goType string) (bool, string, string) {
 		return true, "string", "binary"
 	case "interface{}":
 		return true, "object", ""
-
-	case "time.Time":
-		// This is a special case. While not strictly a primitive type, it's
-		// something we can all agree is as simple as it needs to be.
-		return true, "string", "date-time"
-	}
-
-	return false, "", ""
-}
-
-func IsMap(goType string) (bool, string, string) {
-
-	rxMap := regexp.MustCompile(`map\[(.+)\]\**(.+)`)
-	if rxMap.MatchString(goType) {
-		matches := rxMap.FindStringSubmatch(goType)
-		return true, matches[1], matches[2]
 	}
 
 	return false, "", ""
@@ -111,3 +96,57 @@ func IsSlice(goType string) (bool, string) {
 
 	return false, ""
 }
+
+// The shorthands that can be used in place of a MIME type in the @Accept and
+// @Produce tags.
+var mediaTypeShorthands map[string]string = map[string]string{
+	"json":                  "application/json",
+	"xml":                   "application/xml",
+	"yaml":                  "application/x-yaml",
+	"plain":                 "text/plain",
+	"text":                  "text/plain",
+	"html":                  "text/html",
+	"csv":                   "text/csv",
+	"form":                  "application/x-www-form-urlencoded",
+	"x-www-form-urlencoded": "application/x-www-form-urlencoded",
+	"multipart":             "multipart/form-data",
+	"mpfd":                  "multipart/form-data",
+	"octet-stream":          "application/octet-stream",
+	"binary":                "application/octet-stream",
+	"pdf":                   "application/pdf",
+	"zip":                   "application/zip",
+	"png":                   "image/png",
+	"jpeg":                  "image/jpeg",
+	"gif":                   "image/gif",
+	"event-stream":          "text/event-stream",
+	"json-api":              "application/vnd.api+json",
+	"json-stream":           "application/x-json-stream",
+}
+
+// Parses a comma-separated list of MIME types and/or shorthands, returning the
+// list of MIME types.
+func parseMediaTypes(raw string, pos token.Pos) []string {
+
+	mediaTypes := make([]string, 0)
+
+	for _, mediaType := range strings.Split(raw, ",") {
+		mediaType = strings.TrimSpace(mediaType)
+		mediaType = strings.ToLower(mediaType)
+
+		if mediaType == "" {
+			continue
+		}
+
+		if full, ok := mediaTypeShorthands[mediaType]; ok {
+			mediaType = full
+		} else if !strings.Contains(mediaType, "/") {
+			reportWarning(pos, "unknown-media-type", "Unrecognized MIME type shorthand: %s", mediaType)
+		}
+
+		if !sContains(mediaTypes, mediaType) {
+			mediaTypes = append(mediaTypes, mediaType)
+		}
+	}
+
+	return mediaTypes
+}
@@ -3,29 +3,39 @@ package main
 import (
 	"github.com/jackmanlabs/errors"
 	"go/ast"
-	"go/build"
-	"go/parser"
 	"go/token"
 	"log"
 	"strings"
 )
 
-func getRelevantComments(pkgPath string) ([]string, error) {
+/*
+A comment block, along with the position where it was found. The position is
+necessary to resolve the types referenced by the annotations in the block.
 
-	bpkg, err := build.Import(pkgPath, srcPath, 0)
-	if err != nil {
-		return []string{}, nil
-	}
+The lines of the block are kept along with their own positions, so that
+problems with an annotation can be reported on the line where it's found.
+*/
+type CommentBlock struct {
+	Text  string
+	Pos   token.Pos
+	Lines []CommentLine
+}
 
-	fset := token.NewFileSet()
-	pkgs, err := parser.ParseDir(fset, bpkg.Dir, nil, parser.AllErrors|parser.ParseComments)
+type CommentLine struct {
+	Text string
+	Pos  token.Pos
+}
+
+func getRelevantComments(pkgPath string) ([]CommentBlock, error) {
+
+	pkg, err := getPackage(pkgPath)
 	if err != nil {
 		return nil, errors.Stack(err)
 	}
 
-	commentVisitor := &CommentVisitor{Fset: fset}
-	for _, pkg := range pkgs {
-		ast.Walk(commentVisitor, pkg)
+	commentVisitor := &CommentVisitor{Fset: fileSet}
+	for _, file := range pkg.Syntax {
+		ast.Walk(commentVisitor, file)
 	}
 
 	return commentVisitor.Comments, nil
@@ -36,7 +46,7 @@ We're using a map for the imports so we don't have to worry about duplicates.
 */
 type CommentVisitor struct {
 	Fset     *token.FileSet
-	Comments []string
+	Comments []CommentBlock
 }
 
 func (this *CommentVisitor) Visit(node ast.Node) (w ast.Visitor) {
@@ -50,10 +60,16 @@ func (this *CommentVisitor) Visit(node ast.Node) (w ast.Visitor) {
 
 	case *ast.CommentGroup:
 		if this.Comments == nil {
-			this.Comments = make([]string, 0)
+			this.Comments = make([]CommentBlock, 0)
 		}
 
-		this.Comments = append(this.Comments, t.Text())
+		commentBlock := CommentBlock{
+			Text:  t.Text(),
+			Pos:   t.Pos(),
+			Lines: commentLines(t),
+		}
+
+		this.Comments = append(this.Comments, commentBlock)
 
 		return nil
 
@@ -65,20 +81,48 @@ func (this *CommentVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	return this
 }
 
-func extractOperationComments(comments []string) []string {
+/*
+Splits the comments of a group into lines, stripping the comment markers. Unlike
+CommentGroup.Text(), no lines are dropped, and each one keeps its position.
+*/
+func commentLines(group *ast.CommentGroup) []CommentLine {
+
+	lines := make([]CommentLine, 0)
+
+	for _, comment := range group.List {
+		text := comment.Text
+
+		if strings.HasPrefix(text, "//") {
+			lines = append(lines, CommentLine{Text: text[2:], Pos: comment.Pos()})
+			continue
+		}
+
+		// A /*-style comment may span several lines.
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+		offset := 2
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, CommentLine{Text: line, Pos: comment.Pos() + token.Pos(offset)})
+			offset += len(line) + 1
+		}
+	}
+
+	return lines
+}
+
+func extractOperationComments(comments []CommentBlock) []CommentBlock {
 	return extractComments(comments, "@Router")
 }
 
-func extractApiComments(comments []string) []string {
+func extractApiComments(comments []CommentBlock) []CommentBlock {
 	return extractComments(comments, "@APITitle")
 }
 
-func extractComments(comments []string, keyword string) []string {
+func extractComments(comments []CommentBlock, keyword string) []CommentBlock {
 
-	newComments := make([]string, 0)
+	newComments := make([]CommentBlock, 0)
 
 	for _, comment := range comments {
-		if strings.Contains(comment, keyword) {
+		if strings.Contains(comment.Text, keyword) {
 			newComments = append(newComments, comment)
 		}
 	}
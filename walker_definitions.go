@@ -2,90 +2,192 @@ package main
 
 import (
 	"fmt"
-	"github.com/jackmanlabs/bucket/jlog"
+	"github.com/go-openapi/spec"
 	"github.com/jackmanlabs/errors"
 	"go/ast"
-	"go/build"
-	"go/parser"
 	"go/token"
+	"go/types"
 	"log"
+	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 )
 
-func findDefinition(referringPackage, typeName string) (*DefinitionIntermediate, error) {
+// The type arguments are nil, unless the type is an instantiation of a generic
+// type.
+func findDefinition(obj *types.TypeName, typeArgs []types.Type) (*DefinitionIntermediate, error) {
 
-	pkgInfo := pkgInfos[referringPackage]
-	typeName = strings.TrimPrefix(typeName, "*")
-	importPaths := possibleImportPaths(pkgInfo, typeName)
+	if obj.Pkg() == nil {
+		return nil, errors.New("Predeclared types can't be defined: " + obj.Name())
+	}
+
+	importPath := obj.Pkg().Path()
 
-	if len(importPaths) == 0 {
-		log.Print("ERROR: Import paths not available for this type: ", typeName)
-		jlog.Log(pkgInfo)
+	pkg, err := getPackage(importPath)
+	if err != nil {
+		return nil, errors.Stack(err)
 	}
 
-	if len(importPaths) > 1 {
-		log.Printf("WARNING: Multiple package candidates found for type (%s):", typeName)
-		jlog.Log(importPaths)
+	for _, file := range pkg.Syntax {
+		definitionVisitor := &DefinitionVisitor{
+			Fset:       fileSet,
+			Info:       pkg.TypesInfo,
+			Object:     obj,
+			TypeParams: typeParameterMap(obj, typeArgs),
+		}
+
+		ast.Walk(definitionVisitor, file)
+
+		if definitionVisitor.Definition != nil {
+			definition := definitionVisitor.Definition
+			definition.Object = obj
+			definition.TypeArgs = typeArgs
+			definition.PackageName = pkg.Name
+			definition.PackagePath = importPath
+
+			// Every instantiation of a generic type is a model of its own.
+			definition.Name = instanceName(definition.Name, typeArgs)
+			if definition.CustomName != "" {
+				definition.CustomName = instanceName(definition.CustomName, typeArgs)
+			}
+
+			// If this definition is an enum (underlying type is primitive), then we assume it's an enum type that needs enum values.
+			if isPrimitive, _, _ := IsPrimitive(definition.UnderlyingType); isPrimitive {
+				values, names, descriptions, err := findEnumValues(obj)
+				if err != nil {
+					return nil, errors.Stack(err)
+				}
+				definition.Enums = values
+				definition.EnumNames = names
+				definition.EnumDescriptions = descriptions
+
+				err = applyEnumStrings(definition)
+				if err != nil {
+					return nil, errors.Stack(err)
+				}
+			}
+
+			applyMarshalers(definition)
+
+			return definition, nil
+		}
 	}
 
-	if strings.Contains(typeName, ".") {
-		index := strings.Index(typeName, ".") + 1
-		typeName = typeName[index:]
+	return nil, nil
+}
+
+/*
+Replaces the values of an enum with the strings they're written as on the wire,
+if the type has a MarshalText or MarshalJSON method we can make sense of.
+Constants that the method doesn't map are dropped, as are those that share a
+string with an earlier constant.
+*/
+func applyEnumStrings(definition *DefinitionIntermediate) error {
+
+	if definition.UnderlyingType == "string" {
+		return nil
 	}
 
-	for _, importPath := range importPaths {
+	strs, ok, err := findEnumStrings(definition.Object)
+	if err != nil {
+		return errors.Stack(err)
+	}
+	if !ok {
+		return nil
+	}
 
-		if importPath == "" {
-			log.Print("Import path is blank!")
+	var (
+		values       []string = make([]string, 0)
+		names        []string = make([]string, 0)
+		descriptions []string = make([]string, 0)
+	)
+
+	for i, value := range definition.Enums {
+		s, ok := strs[value]
+		if !ok {
+			reportWarning(definition.Object.Pos(), "unmapped-enum", "The string value of %s (%s) could not be determined; it's left out of the enum.", definition.EnumNames[i], value)
+			continue
 		}
 
-		bpkg, err := build.Import(importPath, srcPath, 0)
-		if err != nil {
-			return nil, errors.Stack(err)
+		// Several constants may be written the same way.
+		if sContains(values, strconv.Quote(s)) {
+			continue
 		}
 
-		fset := token.NewFileSet()
-		pkgs, err := parser.ParseDir(fset, bpkg.Dir, nil, parser.AllErrors|parser.ParseComments)
-		if err != nil {
-			return nil, errors.Stack(err)
-		}
+		values = append(values, strconv.Quote(s))
+		names = append(names, definition.EnumNames[i])
+		descriptions = append(descriptions, definition.EnumDescriptions[i])
+	}
 
-		for _, pkg := range pkgs {
-			definitionVisitor := &DefinitionVisitor{
-				Fset:     fset,
-				TypeName: typeName,
-			}
+	definition.UnderlyingType = "string"
+	definition.EnumStrings = true
+	definition.Enums = values
+	definition.EnumNames = names
+	definition.EnumDescriptions = descriptions
 
-			ast.Walk(definitionVisitor, pkg)
+	return nil
+}
 
-			if definitionVisitor.Definition != nil {
-				definition := definitionVisitor.Definition
-				definition.PackageName = pkg.Name
-				definition.PackagePath = importPath
+/*
+Types that marshal themselves don't look like their Go definition on the wire.
+In order of precedence:
 
-				// If this definition is an enum (underlying type is primitive), then we assume it's an enum type that needs enum values.
-				if isPrimitive, _, _ := IsPrimitive(definition.UnderlyingType); isPrimitive {
-					values, err := findEnumValues(definition.PackagePath, definition.Name)
-					if err != nil {
-						return nil, errors.Stack(err)
-					}
-					definition.Enums = values
-				}
+  - A type documented with the @SwaggerType tag is described as declared.
+  - A json.Marshaler could produce anything, so it's described as such, with a
+    warning that suggests the @SwaggerType tag.
+  - An encoding.TextMarshaler is written as a string (string enums, found by
+    applyEnumStrings, are left as they are).
+*/
+func applyMarshalers(definition *DefinitionIntermediate) {
 
-				return definition, nil
-			}
+	obj := definition.Object
+
+	switch {
+	case definition.WireType != "":
+		// Declared by the @SwaggerType tag.
+
+	case lookupStringerMethod(obj, "MarshalJSON") != nil:
+		if definition.EnumStrings {
+			// The enum strings were found by way of MarshalJSON.
+			return
+		}
+		reportWarning(obj.Pos(), "custom-marshaler", "%s implements json.Marshaler, so its schema is unknown. Declare it with the @SwaggerType tag.", obj.Name())
+		definition.MarshalsItself = true
+
+	case lookupStringerMethod(obj, "MarshalText") != nil:
+		if definition.UnderlyingType == "string" && len(definition.Enums) > 0 {
+			return
 		}
+		definition.WireType = "string"
+
+	default:
+		return
 	}
 
-	return nil, nil
+	// The fields (or enum values) of the type don't describe it anymore.
+	definition.Members = make(map[string]SchemerDefiner)
+	definition.Composite = nil
+	definition.EmbeddedTypes = nil
+	definition.Enums = nil
+	definition.EnumNames = nil
+	definition.EnumDescriptions = nil
 }
 
 type DefinitionVisitor struct {
 	Fset       *token.FileSet
-	TypeName   string
+	Info       *types.Info
+	Object     *types.TypeName                 // The type we're looking for.
+	TypeParams map[*types.TypeParam]types.Type // If the type is a generic type being instantiated, the type arguments of its type parameters.
 	Definition *DefinitionIntermediate
+	DeclDoc    *ast.CommentGroup // The documentation of the type declaration being visited, if it declares a single type.
+}
+
+// Returns the type of the expression, as it is in the instantiation being
+// defined, if any.
+func (this *DefinitionVisitor) typeOf(expr ast.Expr) types.Type {
+	return substituteTypeParameters(this.Info.TypeOf(expr), this.TypeParams)
 }
 
 func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
@@ -105,15 +207,46 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 
 	switch t := node.(type) {
 
+	case *ast.GenDecl:
+		// The documentation of a lone type declaration belongs to the
+		// declaration, rather than to the type spec:
+		//
+		//	// Documentation
+		//	type Foo struct {}
+		this.DeclDoc = nil
+		if t.Tok == token.TYPE && !t.Lparen.IsValid() {
+			this.DeclDoc = t.Doc
+		}
+
 	case *ast.TypeSpec:
-		if t.Name.String() == this.TypeName {
+		if this.Info.Defs[t.Name] == this.Object {
+			doc := t.Doc
+			if doc == nil {
+				doc = this.DeclDoc
+			}
+
 			this.Definition = &DefinitionIntermediate{
 				Name:           t.Name.String(),
 				Comment:        t.Comment.Text(),
-				Documentation:  t.Doc.Text(),
-				UnderlyingType: resolveTypeExpression(t.Type),
+				Documentation:  doc.Text(),
+				CustomName:     parseSwaggerName(doc.Text()),
+				UnderlyingType: resolveType(this.Object.Type().Underlying()),
 				Members:        make(map[string]SchemerDefiner),
 			}
+
+			if doc != nil {
+				this.Definition.WireType, this.Definition.WireFormat = parseSwaggerType(doc.Text(), doc.Pos())
+			}
+
+			underlyingType := substituteTypeParameters(this.Object.Type().Underlying(), this.TypeParams)
+			switch underlyingType.(type) {
+			case *types.Slice, *types.Array, *types.Map:
+				this.Definition.Composite = this.describeType(underlyingType, t.Type, t.Name.String(), make(ValidationMap))
+			}
+
+			// The type parameters of a generic type aren't fields.
+			ast.Walk(this, t.Type)
+			return nil
 		} else {
 			return nil
 		}
@@ -136,43 +269,84 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 			return nil
 		}
 
-		if len(t.Names) > 1 {
-			// On our test code base, this was never printed.
-			log.Print("WARNING: Multiple names discovered.")
+		var (
+			jsonTag   JsonTag
+			xmlTag    XmlTag
+			hasXmlTag bool
+		)
+		if t.Tag != nil {
+			jsonTag = parseJsonTag(t.Tag.Value)
+			xmlTag, hasXmlTag = parseXmlTag(t.Tag.Value)
 		}
 
-		// Handle embedded structs.
-		if len(t.Names) == 0 {
-			//ast.Fprint(os.Stdout, this.Fset, t, nil)
+		goTypeInfo := this.typeOf(t.Type)
 
-			if this.Definition.EmbeddedTypes == nil {
-				this.Definition.EmbeddedTypes = make([]string, 0)
+		if hasXmlTag {
+			if len(t.Names) == 1 && t.Names[0].Name == "XMLName" && isXmlName(goTypeInfo) {
+				// The XMLName field names the root element, rather than an
+				// element of its own.
+				if root := xmlTag.XMLObject(); root != nil {
+					this.Definition.Xml = root
+				}
+				hasXmlTag = false
+			} else if this.Definition.Xml == nil && this.Definition.Name != "" && len(this.TypeParams) == 0 {
+				// Otherwise, encoding/xml names it after the Go type, which
+				// the model isn't necessarily named after.
+				this.Definition.Xml = &spec.XMLObject{Name: this.Definition.Name}
 			}
-			embedded := resolveTypeExpression(t.Type)
-			this.Definition.EmbeddedTypes = append(this.Definition.EmbeddedTypes, embedded)
-			return nil
 		}
 
-		name := t.Names[0].String()
-
-		// Ignore fields that are not exported.
-		name_ := []rune(name)
-		if unicode.IsLower(name_[0]) {
+		if jsonTag.Ignore {
 			return nil
 		}
 
-		var (
-			jsonName      string
-			jsonOmitEmpty bool
-			validations   ValidationMap
-		)
+		var names []string
 
-		if t.Tag != nil {
-			jsonName, jsonOmitEmpty = parseJsonInfo(t.Tag.Value)
-			if jsonName == "-" {
+		if len(t.Names) == 0 {
+			// An embedded field.
+			embedded := namedType(goTypeInfo)
+			if embedded == nil {
+				reportProblem(t.Pos(), "unresolved-type", "Embedded type could not be resolved: %s", types.ExprString(t.Type))
+				return nil
+			}
+
+			_, isStruct := embedded.Underlying().(*types.Struct)
+			_, isPointer := types.Unalias(goTypeInfo).(*types.Pointer)
+
+			// The fields of an embedded struct are promoted, unless its JSON tag
+			// gives it a name, just as encoding/json does it.
+			if isStruct && jsonTag.Name == "" {
+				if isPointer && !embedded.Obj().Exported() {
+					// encoding/json writes their fields, but can't allocate
+					// them when reading.
+					reportWarning(t.Pos(), "unexported-embedded-pointer", "%s embeds a pointer to an unexported struct (%s), which encoding/json can't decode into.", this.Object.Name(), embedded.Obj().Name())
+				}
+
+				if this.Definition.EmbeddedTypes == nil {
+					this.Definition.EmbeddedTypes = make([]*types.Named, 0)
+				}
+				this.Definition.EmbeddedTypes = append(this.Definition.EmbeddedTypes, embedded)
 				return nil
 			}
 
+			// Otherwise, it's a field like any other, named after its type.
+			// encoding/json ignores embedded types that are neither exported
+			// nor structs, but not unexported structs, which it writes by the
+			// name their JSON tag gives them.
+			if !isStruct && !embedded.Obj().Exported() {
+				return nil
+			}
+
+			names = []string{embedded.Obj().Name()}
+		} else {
+			// Each of the names declares a field of its own.
+			for _, ident := range t.Names {
+				names = append(names, ident.Name)
+			}
+		}
+
+		var validations ValidationMap
+		if t.Tag != nil {
 			validations = parseValidateTag(t.Tag.Value)
 		} else {
 			validations = make(ValidationMap)
@@ -183,66 +357,51 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 			desc = parseMemberDescription(t.Comment.Text())
 		}
 
-		goType := resolveTypeExpression(t.Type)
-
-		var member SchemerDefiner
-
-		if isMap, k, v := IsMap(goType); isMap {
-			keyType := &MemberIntermediate{
-				Type:        k,
-				Name:        name,
-				Validations: validations,
-			}
+		for _, name := range names {
 
-			valueType := &MemberIntermediate{
-				Type:        v,
-				Name:        name,
-				Validations: validations,
+			// Ignore fields that are not exported (embedded types were dealt
+			// with above).
+			if len(t.Names) > 0 && !ast.IsExported(name) {
+				continue
 			}
 
-			member = &MapIntermediate{
-				Name:          name,
-				Type:          goType,
-				JsonName:      jsonName,
-				JsonOmitEmpty: jsonOmitEmpty,
-				ValueType:     valueType,
-				KeyType:       keyType,
-				Description:   desc,
-				Validations:   validations,
-				Deprecated:    controls.Deprecated,
+			member := this.describeType(goTypeInfo, t.Type, name, validations)
+
+			switch m := member.(type) {
+			case *MapIntermediate:
+				m.JsonName = jsonTag.Name
+				m.JsonOmitEmpty = jsonTag.OmitEmpty
+				m.Description = desc
+				m.Deprecated = controls.Deprecated
+			case *SliceIntermediate:
+				m.JsonName = jsonTag.Name
+				m.JsonOmitEmpty = jsonTag.OmitEmpty
+				m.Description = desc
+				m.Deprecated = controls.Deprecated
+				if hasXmlTag {
+					m.Xml = &xmlTag
+				}
+			case *MemberIntermediate:
+				m.JsonName = jsonTag.Name
+				m.JsonOmitEmpty = jsonTag.OmitEmpty
+				m.JsonString = jsonTag.String
+				m.Description = desc
+				m.Deprecated = controls.Deprecated
+				if hasXmlTag {
+					m.Xml = &xmlTag
+				}
 			}
 
-		} else if isSlice, v := IsSlice(goType); isSlice {
-			valueType := &MemberIntermediate{
-				Type:        v,
-				Name:        name,
-				Validations: validations,
+			// Swagger can only describe the element that wraps the elements
+			// of a slice.
+			_, isSlice := member.(*SliceIntermediate)
+			if hasXmlTag && (len(xmlTag.Parents) > 1 || len(xmlTag.Parents) == 1 && !isSlice) {
+				reportWarning(t.Pos(), "unsupported-xml-path", "The XML path of %s can't be described; only the name of its element is.", name)
 			}
 
-			member = &SliceIntermediate{
-				Name:          name,
-				Type:          goType,
-				JsonName:      jsonName,
-				JsonOmitEmpty: jsonOmitEmpty,
-				ValueType:     valueType,
-				Description:   desc,
-				Validations:   validations,
-				Deprecated:    controls.Deprecated,
-			}
-		} else {
-			member = &MemberIntermediate{
-				Type:          goType,
-				Name:          name,
-				JsonName:      jsonName,
-				JsonOmitEmpty: jsonOmitEmpty,
-				Description:   desc,
-				Validations:   validations,
-				Deprecated:    controls.Deprecated,
-			}
+			this.Definition.Members[name] = member
 		}
 
-		this.Definition.Members[name] = member
-
 		return nil
 
 	case *ast.FuncDecl:
@@ -259,51 +418,295 @@ func (this *DefinitionVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	return this
 }
 
-func resolveTypeExpression(expr ast.Expr) string {
-
-	switch t := expr.(type) {
-	case *ast.StarExpr:
-		return "*" + resolveTypeExpression(t.X)
-	case *ast.ArrayType:
-		return "[]" + resolveTypeExpression(t.Elt)
-	case *ast.Ident:
-		return t.Name
-	case *ast.SelectorExpr:
-		return resolveTypeExpression(t.X) + "." + t.Sel.Name
-	case *ast.MapType:
-		return fmt.Sprintf("map[%s]%s", resolveTypeExpression(t.Key), resolveTypeExpression(t.Value))
-	case *ast.InterfaceType:
+// Returns the anonymous struct type that an expression declares, if any,
+// possibly by pointer.
+func anonymousStruct(expr ast.Expr) *ast.StructType {
+
+	for {
+		switch t := expr.(type) {
+		case *ast.StructType:
+			return t
+		case *ast.StarExpr:
+			expr = t.X
+		case *ast.ParenExpr:
+			expr = t.X
+		default:
+			return nil
+		}
+	}
+}
+
+/*
+Describes a type, such as the type of a field, or the underlying type of a named
+type. Slices, arrays and maps are described recursively, so that any nesting of
+them, such as map[string][][4]int, is described in full. Pointers to them are
+described like the types they point to, since encoding/json writes both the
+same way.
+
+The expression is the one that the type was declared with, if known. It's used
+to find anonymous structs, which are described inline. The name and validations
+are given to the elements of slices, arrays and maps, as well as to the type
+itself.
+*/
+func (this *DefinitionVisitor) describeType(goTypeInfo types.Type, expr ast.Expr, name string, validations Validator) SchemerDefiner {
+
+	goType := resolveType(goTypeInfo)
+
+	for {
+		if paren, ok := expr.(*ast.ParenExpr); ok {
+			expr = paren.X
+		} else {
+			break
+		}
+	}
+
+	switch t := types.Unalias(goTypeInfo).(type) {
+
+	case *types.Pointer:
+		switch types.Unalias(t.Elem()).Underlying().(type) {
+		case *types.Slice, *types.Array, *types.Map:
+			if _, isNamed := types.Unalias(t.Elem()).(*types.Named); !isNamed {
+				var elemExpr ast.Expr
+				if star, ok := expr.(*ast.StarExpr); ok {
+					elemExpr = star.X
+				}
+				return this.describeType(t.Elem(), elemExpr, name, validations)
+			}
+		}
+
+	case *types.Slice:
+		if isPrimitive, _, _ := IsPrimitive(goType); isPrimitive {
+			// []byte is written as a base64 string.
+			break
+		}
+
+		var elemExpr ast.Expr
+		if array, ok := expr.(*ast.ArrayType); ok {
+			elemExpr = array.Elt
+		}
+
+		return &SliceIntermediate{
+			Name:        name,
+			Type:        goType,
+			ValueType:   this.describeType(t.Elem(), elemExpr, name, validations),
+			Validations: validations,
+		}
+
+	case *types.Array:
+		var elemExpr ast.Expr
+		if array, ok := expr.(*ast.ArrayType); ok {
+			elemExpr = array.Elt
+		}
+
+		return &SliceIntermediate{
+			Name:        name,
+			Type:        goType,
+			ValueType:   this.describeType(t.Elem(), elemExpr, name, validations),
+			Validations: validations,
+			IsArray:     true,
+			Length:      t.Len(),
+		}
+
+	case *types.Map:
+		var keyExpr, valueExpr ast.Expr
+		if mapType, ok := expr.(*ast.MapType); ok {
+			keyExpr = mapType.Key
+			valueExpr = mapType.Value
+		}
+
+		return &MapIntermediate{
+			Name:        name,
+			Type:        goType,
+			KeyType:     this.describeType(t.Key(), keyExpr, name, validations),
+			ValueType:   this.describeType(t.Elem(), valueExpr, name, validations),
+			Validations: validations,
+		}
+	}
+
+	member := &MemberIntermediate{
+		Type:        goType,
+		Object:      namedObject(goTypeInfo),
+		TypeArgs:    typeArguments(goTypeInfo),
+		Name:        name,
+		Validations: validations,
+	}
+
+	if expr != nil {
+		member.Pos = expr.Pos()
+	}
+
+	// Anonymous structs are described inline, rather than by reference.
+	if structType := anonymousStruct(expr); structType != nil {
+		member.Inline = this.inlineDefinition(structType)
+	}
+
+	return member
+}
+
+// Builds a definition of an anonymous struct, which is never stored, but
+// belongs to the member declared with it.
+func (this *DefinitionVisitor) inlineDefinition(structType *ast.StructType) *DefinitionIntermediate {
+
+	inlineVisitor := &DefinitionVisitor{
+		Fset:       this.Fset,
+		Info:       this.Info,
+		Object:     this.Object,
+		TypeParams: this.TypeParams,
+		Definition: &DefinitionIntermediate{
+			UnderlyingType: "struct",
+			Members:        make(map[string]SchemerDefiner),
+		},
+	}
+
+	ast.Walk(inlineVisitor, structType.Fields)
+
+	return inlineVisitor.Definition
+}
+
+/*
+Returns the type name object that a type refers to, ignoring any pointers. Nil is
+returned if the type isn't a named type. For an instantiated generic type, this
+is the object of the generic type.
+*/
+func namedObject(t types.Type) *types.TypeName {
+
+	named := namedType(t)
+	if named == nil {
+		return nil
+	}
+
+	return named.Obj()
+}
+
+// Returns the named type that a type refers to, ignoring any pointers. Nil is
+// returned if the type isn't a named type.
+func namedType(t types.Type) *types.Named {
+
+	t = types.Unalias(t)
+	if p, ok := t.(*types.Pointer); ok {
+		return namedType(p.Elem())
+	}
+
+	if named, ok := t.(*types.Named); ok {
+		return named
+	}
+
+	return nil
+}
+
+/*
+Creates the textual description of a type, in the same form in which it would
+have been written in the package where it's declared. Named types are always
+qualified with their package name.
+*/
+func resolveType(t types.Type) string {
+
+	switch t := t.(type) {
+	case *types.Alias:
+		return resolveType(types.Unalias(t))
+	case *types.Pointer:
+		return "*" + resolveType(t.Elem())
+	case *types.Slice:
+		return "[]" + resolveType(t.Elem())
+	case *types.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), resolveType(t.Elem()))
+	case *types.Basic:
+		return t.Name()
+	case *types.Named:
+		obj := t.Obj()
+		if obj.Pkg() == nil {
+			// Predeclared types, such as 'error'.
+			return obj.Name()
+		}
+		name := obj.Pkg().Name() + "." + obj.Name()
+		if typeArgs := typeArguments(t); len(typeArgs) > 0 {
+			argNames := make([]string, 0)
+			for _, typeArg := range typeArgs {
+				argNames = append(argNames, resolveType(typeArg))
+			}
+			name += "[" + strings.Join(argNames, ", ") + "]"
+		}
+		return name
+	case *types.TypeParam:
+		return t.Obj().Name()
+	case *types.Map:
+		return fmt.Sprintf("map[%s]%s", resolveType(t.Key()), resolveType(t.Elem()))
+	case *types.Interface:
 		return "interface{}"
-	case *ast.StructType:
+	case *types.Struct:
 		return "struct"
 	default:
 		return fmt.Sprintf("Unknown<%T>", t)
 	}
+}
 
+type JsonTag struct {
+	Name      string // The name given to the field, if any. Otherwise, the Go name is used.
+	Ignore    bool   // If the field is never written.
+	OmitEmpty bool   // If the omitempty or omitzero option was given, so that the field may be left out.
+	String    bool   // If the string option was given, so that the value is written inside a string.
 }
 
-func parseJsonInfo(s string) (string, bool) {
-	rxJson := regexp.MustCompile(`json:"([^"]+)"`)
+/*
+Parses the 'json' key of a struct tag (the raw literal, with its quotes) the way
+encoding/json does it. In particular, a name that encoding/json wouldn't accept
+is ignored, and '-,' names the field '-' rather than ignoring it.
+*/
+func parseJsonTag(literal string) JsonTag {
+
+	var jsonTag JsonTag
+
+	tag, err := strconv.Unquote(literal)
+	if err != nil {
+		return jsonTag
+	}
+
+	value, ok := reflect.StructTag(tag).Lookup("json")
+	if !ok {
+		return jsonTag
+	}
 
-	if !rxJson.MatchString(s) {
-		return "", false
+	if value == "-" {
+		jsonTag.Ignore = true
+		return jsonTag
 	}
 
-	matches := rxJson.FindStringSubmatch(s)
-	words := strings.Split(matches[1], ",")
+	words := strings.Split(value, ",")
 
-	name := words[0]
-	if len(words) == 1 {
-		return name, false
+	if isValidJsonName(words[0]) {
+		jsonTag.Name = words[0]
 	}
 
 	for _, word := range words[1:] {
-		if word == "omitempty" {
-			return name, true
+		switch word {
+		case "omitempty", "omitzero":
+			jsonTag.OmitEmpty = true
+		case "string":
+			jsonTag.String = true
 		}
 	}
 
-	return name, false
+	return jsonTag
+}
+
+// The same test as encoding/json's.
+func isValidJsonName(name string) bool {
+
+	if name == "" {
+		return false
+	}
+
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but otherwise any
+			// punctuation chars are allowed in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+
+	return true
 }
 
 func parseMemberDescription(s string) string {
@@ -358,6 +761,51 @@ type OpenApiControls struct {
 	Deprecated bool
 }
 
+/*
+Types can be published under a name of their choosing, regardless of the
+naming convention, with the @SwaggerName tag in their documentation:
+
+	// @SwaggerName Contact
+	type contactRecord struct {}
+*/
+func parseSwaggerName(s string) string {
+
+	rxSwaggerName := regexp.MustCompile(`@SwaggerName\s+([\w\.-]+)`)
+
+	if !rxSwaggerName.MatchString(s) {
+		return ""
+	}
+
+	return rxSwaggerName.FindStringSubmatch(s)[1]
+}
+
+/*
+Types that marshal themselves can declare what they look like on the wire with
+the @SwaggerType tag in their documentation, followed by a Swagger type and,
+optionally, a format:
+
+	// @SwaggerType string date
+	type Date struct {}
+*/
+func parseSwaggerType(s string, pos token.Pos) (string, string) {
+
+	rxSwaggerType := regexp.MustCompile(`@SwaggerType\s+(\w+)(?:[ \t]+([\w-]+))?`)
+
+	if !rxSwaggerType.MatchString(s) {
+		return "", ""
+	}
+
+	matches := rxSwaggerType.FindStringSubmatch(s)
+
+	switch matches[1] {
+	case "string", "integer", "number", "boolean", "object":
+		return matches[1], matches[2]
+	}
+
+	reportProblem(pos, "unparsed-annotation", "Unsupported type for the @SwaggerType tag: %s", matches[1])
+	return "", ""
+}
+
 func parseOpenApiControls(s string) OpenApiControls {
 
 	var controls OpenApiControls
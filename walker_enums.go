@@ -1,76 +1,67 @@
 package main
 
 import (
-	"fmt"
-	"github.com/jackmanlabs/bucket/jlog"
 	"github.com/jackmanlabs/errors"
 	"go/ast"
-	"go/build"
-	"go/parser"
+	"go/constant"
 	"go/token"
+	"go/types"
 	"log"
+	"strconv"
 	"strings"
 )
 
-func findEnumValues(referringPackage, typeName string) ([]string, error) {
-
-	pkgInfo := pkgInfos[referringPackage]
-	typeName = strings.TrimPrefix(typeName, "*")
-
-	importPaths := possibleImportPaths(pkgInfo, typeName)
-
-	if len(importPaths) == 0 {
-		log.Print("ERROR: Import paths not available for this enum type: ", typeName)
-		jlog.Log(pkgInfo)
-	}
-
-	if len(importPaths) > 1 {
-		log.Printf("WARNING: Multiple package candidates found for enum type (%s):", typeName)
-		jlog.Log(importPaths)
+/*
+Returns the values of the constants declared with the enum type, along with the
+names and the documentation of those constants.
+
+The values are taken from the type checker, which has already evaluated the
+constant declarations, so iota, implicitly repeated expressions, and arithmetic
+on other constants are all accounted for:
+
+	const (
+		KindNone Kind = iota // 0
+		KindPerson           // 1
+		KindCompany          // 2
+		KindAll = KindCompany<<1 - 1 // 3
+	)
+
+Constants that repeat a value that was already declared (aliases, in effect)
+are skipped.
+*/
+func findEnumValues(obj *types.TypeName) ([]string, []string, []string, error) {
+
+	pkg, err := getPackage(obj.Pkg().Path())
+	if err != nil {
+		return nil, nil, nil, errors.Stack(err)
 	}
 
-	if strings.Contains(typeName, ".") {
-		index := strings.Index(typeName, ".") + 1
-		typeName = typeName[index:]
+	enumVisitor := &EnumVisitor{
+		Fset:         fileSet,
+		Info:         pkg.TypesInfo,
+		Object:       obj,
+		Values:       make([]string, 0),
+		Names:        make([]string, 0),
+		Descriptions: make([]string, 0),
+		seen:         make(map[string]bool),
 	}
 
-	for _, importPath := range importPaths {
-
-		if importPath == "" {
-			log.Print("Import path is blank!")
-		}
-
-		bpkg, err := build.Import(importPath, srcPath, 0)
-		if err != nil {
-			return nil, errors.Stack(err)
-		}
-
-		fset := token.NewFileSet()
-		pkgs, err := parser.ParseDir(fset, bpkg.Dir, nil, parser.AllErrors)
-		if err != nil {
-			return nil, errors.Stack(err)
-		}
-
-		for _, pkg := range pkgs {
-			enumVisitor := &EnumVisitor{
-				Fset:     fset,
-				TypeName: typeName,
-				Values:   make([]string, 0),
-			}
-
-			ast.Walk(enumVisitor, pkg)
-
-			return enumVisitor.Values, nil
-		}
+	for _, file := range pkg.Syntax {
+		ast.Walk(enumVisitor, file)
 	}
 
-	return nil, nil
+	return enumVisitor.Values, enumVisitor.Names, enumVisitor.Descriptions, nil
 }
 
 type EnumVisitor struct {
-	Fset     *token.FileSet
-	TypeName string
-	Values   []string
+	Fset         *token.FileSet
+	Info         *types.Info
+	Object       *types.TypeName // The enum type.
+	Values       []string
+	Names        []string // The names of the constants, in the same order as the values.
+	Descriptions []string // The documentation of the constants, in the same order as the values.
+	seen         map[string]bool
+	declDoc      *ast.CommentGroup // The documentation of the declaration being visited, if it declares a single constant.
 }
 
 func (this *EnumVisitor) Visit(node ast.Node) (w ast.Visitor) {
@@ -82,23 +73,47 @@ func (this *EnumVisitor) Visit(node ast.Node) (w ast.Visitor) {
 
 	switch t := node.(type) {
 
+	case *ast.GenDecl:
+		// The documentation of a lone constant declaration belongs to the
+		// declaration, rather than to the value spec:
+		//
+		//	// Documentation
+		//	const Low Level = 1
+		this.declDoc = nil
+		if t.Tok == token.CONST && !t.Lparen.IsValid() {
+			this.declDoc = t.Doc
+		}
+
 	case *ast.ValueSpec:
-		valueType := resolveTypeExpression(t.Type)
-		if valueType != this.TypeName {
-			return nil
+		doc := t.Doc
+		if doc == nil {
+			doc = this.declDoc
 		}
 
-		// Assume we have one name and one value.
-		if len(t.Names) != 1 || len(t.Values) != 1 {
-			log.Print("WARNING: A possible constant declaration was found, but has more than one name or value: " + valueType)
-			return nil
+		// The comment on the same line is usually the most concise.
+		description := constantDescription(t.Comment.Text())
+		if description == "" {
+			description = constantDescription(doc.Text())
 		}
 
-		valueValue := resolveValueExpression(t.Values[0])
+		for _, name := range t.Names {
+			c := this.enumConstant(name)
+			if c == nil {
+				continue
+			}
 
-		//ast.Fprint(os.Stderr, this.Fset, valueValue, nil)
+			value := constantValue(c)
+			if this.seen[value] {
+				continue
+			}
+			this.seen[value] = true
 
-		this.Values = append(this.Values, valueValue)
+			this.Values = append(this.Values, value)
+			this.Names = append(this.Names, c.Name())
+			this.Descriptions = append(this.Descriptions, description)
+		}
+
+		return nil
 
 	case *ast.FuncDecl:
 		// Ignore function declarations.
@@ -114,13 +129,42 @@ func (this *EnumVisitor) Visit(node ast.Node) (w ast.Visitor) {
 	return this
 }
 
-func resolveValueExpression(expr ast.Expr) string {
-	switch t := expr.(type) {
-	case *ast.BasicLit:
-		return t.Value
-	case *ast.UnaryExpr:
-		return t.Op.String() + resolveValueExpression(t.X)
+// Returns the constant declared by the name, if it's a constant of the enum type.
+func (this *EnumVisitor) enumConstant(name *ast.Ident) *types.Const {
+
+	if name.Name == "_" {
+		return nil
+	}
+
+	c, ok := this.Info.Defs[name].(*types.Const)
+	if !ok || !types.Identical(c.Type(), this.Object.Type()) {
+		return nil
+	}
+
+	return c
+}
+
+// Joins the lines of a comment, so that it can be used as a description.
+func constantDescription(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+/*
+Formats the value of a constant the way it would be written in Go, which is the
+way enum values are stored: strings are quoted, numbers are not.
+*/
+func constantValue(c *types.Const) string {
+	return formatConstant(c.Val())
+}
+
+func formatConstant(v constant.Value) string {
+	switch v.Kind() {
+	case constant.String:
+		return strconv.Quote(constant.StringVal(v))
+	case constant.Float:
+		f, _ := constant.Float64Val(v)
+		return strconv.FormatFloat(f, 'g', -1, 64)
 	default:
-		return fmt.Sprintf("Unknown<%T>", t)
+		return v.ExactString()
 	}
 }
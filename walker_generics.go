@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"go/types"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+/*
+Each instantiation of a generic type is a model of its own, since its fields
+depend on the type arguments. A definition is therefore identified by the
+generic type's object along with the type arguments, and named after both:
+
+	Page[User]            ->  PageOfUser
+	Envelope[Page[User]]  ->  EnvelopeOfPageOfUser
+	Pair[User, Company]   ->  PairOfUserAndCompany
+	Page[[]string]        ->  PageOfArrayOfString
+	Page[map[string]int]  ->  PageOfMapOfStringToInt
+
+Non-generic types have no type arguments (nil), and keep their names.
+*/
+
+// Returns the type arguments of an instantiated named type, ignoring pointers.
+// Nil is returned for any other type.
+func typeArguments(t types.Type) []types.Type {
+
+	named := namedType(t)
+	if named == nil || named.TypeArgs().Len() == 0 {
+		return nil
+	}
+
+	typeArgs := make([]types.Type, 0)
+	for i := 0; i < named.TypeArgs().Len(); i++ {
+		typeArgs = append(typeArgs, named.TypeArgs().At(i))
+	}
+
+	return typeArgs
+}
+
+func identicalTypeArguments(a, b []types.Type) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !types.Identical(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the name of the type, including its type arguments, if any.
+func instanceName(name string, typeArgs []types.Type) string {
+	return qualifiedInstanceName(name, typeArgs, 0)
+}
+
+/*
+Returns the name of the type, including its type arguments, each of which is
+qualified by the last n segments of its package path. Since the names of the
+type arguments are no longer ambiguous then, pointers and the lengths of arrays
+are named as well:
+
+	Page[model.Base]     ->  PageOfModelBase
+	Page[*billing.User]  ->  PageOfPointerToBillingUser
+	Page[[4]int]         ->  PageOfArrayOf4Int
+
+If n is 0, the type arguments are named as they are in Go code, without their
+packages, pointers, or lengths.
+*/
+func qualifiedInstanceName(name string, typeArgs []types.Type, n int) string {
+
+	if len(typeArgs) == 0 {
+		return name
+	}
+
+	argNames := make([]string, 0)
+	for _, typeArg := range typeArgs {
+		argNames = append(argNames, typeArgumentName(typeArg, n))
+	}
+
+	return name + "Of" + strings.Join(argNames, "And")
+}
+
+func typeArgumentName(t types.Type, n int) string {
+
+	switch t := types.Unalias(t).(type) {
+	case *types.Pointer:
+		if n > 0 {
+			return "PointerTo" + typeArgumentName(t.Elem(), n)
+		}
+		return typeArgumentName(t.Elem(), n)
+	case *types.Slice:
+		return "ArrayOf" + typeArgumentName(t.Elem(), n)
+	case *types.Array:
+		if n > 0 {
+			return fmt.Sprintf("ArrayOf%d", t.Len()) + typeArgumentName(t.Elem(), n)
+		}
+		return "ArrayOf" + typeArgumentName(t.Elem(), n)
+	case *types.Map:
+		return "MapOf" + typeArgumentName(t.Key(), n) + "To" + typeArgumentName(t.Elem(), n)
+	case *types.Basic:
+		return exportedName(t.Name())
+	case *types.Named:
+		var qualifier string
+		if n > 0 && t.Obj().Pkg() != nil {
+			for _, segment := range strings.Split(pathSuffix(t.Obj().Pkg().Path(), n), "/") {
+				qualifier += exportedName(nonAlphanumeric.ReplaceAllString(segment, ""))
+			}
+		}
+		return qualifier + qualifiedInstanceName(exportedName(t.Obj().Name()), typeArguments(t), n)
+	case *types.Interface:
+		return "Any"
+	}
+
+	return "Object"
+}
+
+// Returns the number of segments in the longest package path among the type
+// arguments, including those of nested type arguments.
+func typeArgumentDepth(typeArgs []types.Type) int {
+
+	depth := 0
+	for _, typeArg := range typeArgs {
+		var d int
+		switch t := types.Unalias(typeArg).(type) {
+		case *types.Pointer:
+			d = typeArgumentDepth([]types.Type{t.Elem()})
+		case *types.Slice:
+			d = typeArgumentDepth([]types.Type{t.Elem()})
+		case *types.Array:
+			d = typeArgumentDepth([]types.Type{t.Elem()})
+		case *types.Map:
+			d = typeArgumentDepth([]types.Type{t.Key(), t.Elem()})
+		case *types.Named:
+			if t.Obj().Pkg() != nil {
+				d = len(strings.Split(t.Obj().Pkg().Path(), "/"))
+			}
+			if d_ := typeArgumentDepth(typeArguments(t)); d_ > d {
+				d = d_
+			}
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+
+	return depth
+}
+
+var nonAlphanumeric *regexp.Regexp = regexp.MustCompile(`[^A-Za-z0-9]`)
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	name_ := []rune(name)
+	name_[0] = unicode.ToUpper(name_[0])
+	return string(name_)
+}
+
+/*
+Maps the type parameters of a generic type to the type arguments of one of its
+instantiations. Nil is returned if the type isn't generic.
+*/
+func typeParameterMap(obj *types.TypeName, typeArgs []types.Type) map[*types.TypeParam]types.Type {
+
+	named, ok := obj.Type().(*types.Named)
+	if !ok || named.TypeParams().Len() != len(typeArgs) || len(typeArgs) == 0 {
+		return nil
+	}
+
+	params := make(map[*types.TypeParam]types.Type)
+	for i, typeArg := range typeArgs {
+		params[named.TypeParams().At(i)] = typeArg
+	}
+
+	return params
+}
+
+/*
+Replaces the type parameters in a type, as written in the declaration of a
+generic type, with the type arguments of an instantiation.
+*/
+func substituteTypeParameters(t types.Type, params map[*types.TypeParam]types.Type) types.Type {
+
+	if len(params) == 0 || t == nil {
+		return t
+	}
+
+	switch t_ := types.Unalias(t).(type) {
+	case *types.TypeParam:
+		if typeArg, ok := params[t_]; ok {
+			return typeArg
+		}
+	case *types.Pointer:
+		return types.NewPointer(substituteTypeParameters(t_.Elem(), params))
+	case *types.Slice:
+		return types.NewSlice(substituteTypeParameters(t_.Elem(), params))
+	case *types.Array:
+		return types.NewArray(substituteTypeParameters(t_.Elem(), params), t_.Len())
+	case *types.Map:
+		return types.NewMap(substituteTypeParameters(t_.Key(), params), substituteTypeParameters(t_.Elem(), params))
+	case *types.Named:
+		typeArgs := typeArguments(t_)
+		if len(typeArgs) == 0 {
+			return t
+		}
+
+		for i, typeArg := range typeArgs {
+			typeArgs[i] = substituteTypeParameters(typeArg, params)
+		}
+
+		instance, err := types.Instantiate(nil, t_.Origin(), typeArgs, false)
+		if err == nil {
+			return instance
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"github.com/jackmanlabs/errors"
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"golang.org/x/tools/go/packages"
+)
+
+/*
+Integer enums are often written to the wire as strings, by a MarshalText method
+that maps each constant to its name, possibly by way of a String method. When
+the mapping can be determined without running any code, the string values are
+the ones that need documenting. These forms are recognized:
+
+	func (this Kind) MarshalText() ([]byte, error) {
+		switch this {
+		case KindPerson:
+			return []byte("person"), nil
+		case KindCompany:
+			return []byte("company"), nil
+		}
+		return nil, nil
+	}
+
+	func (this Kind) String() string {
+		return kindNames[this] // var kindNames = map[Kind]string{KindPerson: "person", ...}
+	}
+
+	func (this Kind) MarshalText() ([]byte, error) {
+		return []byte([...]string{"", "person", "company"}[this]), nil
+	}
+
+encoding/json ignores String methods, so a String method is only followed when
+the MarshalText (or MarshalJSON) method calls it:
+
+	func (this Kind) MarshalText() ([]byte, error) {
+		return []byte(this.String()), nil
+	}
+
+	func (this Kind) MarshalJSON() ([]byte, error) {
+		return json.Marshal(this.String())
+	}
+
+The returned map is keyed by the enum values, formatted like the values returned
+by findEnumValues. False is returned if there is no such method, or if it
+doesn't map any value in a way we can follow.
+*/
+func findEnumStrings(obj *types.TypeName) (map[string]string, bool, error) {
+
+	pkg, err := getPackage(obj.Pkg().Path())
+	if err != nil {
+		return nil, false, errors.Stack(err)
+	}
+
+	stringFn := lookupStringerMethod(obj, "String")
+
+	for _, methodName := range []string{"MarshalText", "MarshalJSON"} {
+		fn := lookupStringerMethod(obj, methodName)
+		if fn == nil {
+			continue
+		}
+
+		decl := findFuncDecl(pkg, fn)
+		if decl == nil || decl.Body == nil {
+			continue
+		}
+
+		// MarshalJSON writes JSON, rather than the strings themselves, so only
+		// the String method it calls can be followed.
+		if methodName == "MarshalText" {
+			if strs, ok := stringerStrings(pkg, obj, decl); ok {
+				return strs, true, nil
+			}
+		}
+
+		if stringFn == nil || !callsFunc(pkg, decl, stringFn) {
+			continue
+		}
+
+		stringDecl := findFuncDecl(pkg, stringFn)
+		if stringDecl == nil || stringDecl.Body == nil {
+			continue
+		}
+
+		if strs, ok := stringerStrings(pkg, obj, stringDecl); ok {
+			return strs, true, nil
+		}
+	}
+
+	return nil, false, nil
+}
+
+// Returns the strings that the method maps the enum values to, if any.
+func stringerStrings(pkg *packages.Package, obj *types.TypeName, decl *ast.FuncDecl) (map[string]string, bool) {
+
+	stringerVisitor := &StringerVisitor{
+		Pkg:     pkg,
+		Object:  obj,
+		Strings: make(map[string]string),
+	}
+
+	ast.Walk(stringerVisitor, decl.Body)
+
+	return stringerVisitor.Strings, len(stringerVisitor.Strings) > 0
+}
+
+// Returns true if the body of the declaration calls the function (or method).
+func callsFunc(pkg *packages.Package, decl *ast.FuncDecl, fn *types.Func) bool {
+
+	calls := false
+
+	ast.Inspect(decl.Body, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpr)
+		if !ok {
+			return !calls
+		}
+
+		var ident *ast.Ident
+		switch f := call.Fun.(type) {
+		case *ast.SelectorExpr:
+			ident = f.Sel
+		case *ast.Ident:
+			ident = f
+		}
+
+		if ident != nil && pkg.TypesInfo.Uses[ident] == fn {
+			calls = true
+		}
+
+		return !calls
+	})
+
+	return calls
+}
+
+// Returns the method, if the type has it with the expected signature.
+func lookupStringerMethod(obj *types.TypeName, name string) *types.Func {
+
+	o, _, _ := types.LookupFieldOrMethod(obj.Type(), true, obj.Pkg(), name)
+	fn, ok := o.(*types.Func)
+	if !ok {
+		return nil
+	}
+
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 {
+		return nil
+	}
+
+	results := sig.Results()
+	switch name {
+	case "String":
+		if results.Len() == 1 && types.Identical(results.At(0).Type(), types.Typ[types.String]) {
+			return fn
+		}
+	case "MarshalText", "MarshalJSON":
+		byteSlice := types.NewSlice(types.Typ[types.Byte])
+		if results.Len() == 2 && types.Identical(results.At(0).Type(), byteSlice) {
+			return fn
+		}
+	}
+
+	return nil
+}
+
+func findFuncDecl(pkg *packages.Package, fn *types.Func) *ast.FuncDecl {
+	for _, file := range pkg.Syntax {
+		for _, decl := range file.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if ok && pkg.TypesInfo.Defs[funcDecl.Name] == fn {
+				return funcDecl
+			}
+		}
+	}
+
+	return nil
+}
+
+type StringerVisitor struct {
+	Pkg     *packages.Package
+	Object  *types.TypeName   // The enum type.
+	Strings map[string]string // map[value]string
+}
+
+func (this *StringerVisitor) Visit(node ast.Node) (w ast.Visitor) {
+
+	switch t := node.(type) {
+
+	case *ast.CaseClause:
+		// Find the string returned by the case, if any.
+		var s string
+		var ok bool
+		for _, stmt := range t.Body {
+			if ret, isReturn := stmt.(*ast.ReturnStmt); isReturn && len(ret.Results) > 0 {
+				s, ok = this.stringValue(ret.Results[0])
+				break
+			}
+		}
+
+		if ok {
+			for _, expr := range t.List {
+				if value, isEnum := this.enumValue(expr); isEnum {
+					this.add(value, s)
+				}
+			}
+		}
+
+	case *ast.IndexExpr:
+		lit := this.compositeLit(t.X)
+		if lit != nil {
+			this.addLiteral(lit)
+		}
+	}
+
+	return this
+}
+
+// Values already mapped (by an earlier case, for example) are left alone.
+func (this *StringerVisitor) add(value, s string) {
+	if _, ok := this.Strings[value]; !ok {
+		this.Strings[value] = s
+	}
+}
+
+/*
+Adds the entries of a map, array, or slice literal. Maps are keyed by the enum
+constants, while arrays and slices are indexed by the values of the constants.
+*/
+func (this *StringerVisitor) addLiteral(lit *ast.CompositeLit) {
+
+	index := int64(0)
+
+	for _, elt := range lit.Elts {
+		key := ast.Expr(nil)
+		value := elt
+
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			key = kv.Key
+			value = kv.Value
+		}
+
+		s, ok := this.stringValue(value)
+
+		if key != nil {
+			tv := this.Pkg.TypesInfo.Types[key]
+			if tv.Value == nil {
+				continue
+			}
+			if i, exact := constant.Int64Val(constant.ToInt(tv.Value)); exact {
+				index = i
+			}
+			if ok {
+				this.add(formatConstant(tv.Value), s)
+			}
+		} else if ok {
+			this.add(formatConstant(constant.MakeInt64(index)), s)
+		}
+
+		index++
+	}
+}
+
+// Returns the literal that the expression is, or that the variable it refers to
+// is initialized with.
+func (this *StringerVisitor) compositeLit(expr ast.Expr) *ast.CompositeLit {
+
+	switch t := expr.(type) {
+	case *ast.CompositeLit:
+		return t
+	case *ast.ParenExpr:
+		return this.compositeLit(t.X)
+	case *ast.Ident:
+		v, ok := this.Pkg.TypesInfo.Uses[t].(*types.Var)
+		if !ok || v.Parent() != this.Pkg.Types.Scope() {
+			// Only package-level variables can be trusted not to change.
+			return nil
+		}
+
+		for _, file := range this.Pkg.Syntax {
+			for _, decl := range file.Decls {
+				genDecl, ok := decl.(*ast.GenDecl)
+				if !ok {
+					continue
+				}
+				for _, spec := range genDecl.Specs {
+					valueSpec, ok := spec.(*ast.ValueSpec)
+					if !ok {
+						continue
+					}
+					for i, name := range valueSpec.Names {
+						if this.Pkg.TypesInfo.Defs[name] == v && i < len(valueSpec.Values) {
+							lit, _ := valueSpec.Values[i].(*ast.CompositeLit)
+							return lit
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the value of the expression, if it's a constant of the enum type.
+func (this *StringerVisitor) enumValue(expr ast.Expr) (string, bool) {
+
+	tv := this.Pkg.TypesInfo.Types[expr]
+	if tv.Value == nil || !types.Identical(tv.Type, this.Object.Type()) {
+		return "", false
+	}
+
+	return formatConstant(tv.Value), true
+}
+
+// Returns the value of the expression, if it's a constant string, possibly
+// converted to a byte slice.
+func (this *StringerVisitor) stringValue(expr ast.Expr) (string, bool) {
+
+	if call, ok := expr.(*ast.CallExpr); ok && len(call.Args) == 1 && this.Pkg.TypesInfo.Types[call.Fun].IsType() {
+		expr = call.Args[0]
+	}
+
+	tv := this.Pkg.TypesInfo.Types[expr]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(tv.Value), true
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"github.com/go-openapi/spec"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+/*
+Routes may produce XML as well as JSON, in which case encoding/xml writes the
+models according to the 'xml' keys of their struct tags:
+
+	type Person struct {
+		XMLName xml.Name `xml:"urn:example person"` // The root element.
+		ID      int      `xml:"id,attr"`
+		Name    string   `xml:"name"`
+		Emails  []string `xml:"emails>email"`
+		Note    string   `xml:",chardata"`
+	}
+
+These are described by the Swagger 'xml' object. Swagger has no notion of
+character data, so those fields are marked with the 'x-xml-chardata' (or
+'x-xml-innerxml') extension instead.
+*/
+type XmlTag struct {
+	Name      string   // The name of the element or attribute, if given.
+	Namespace string   // The namespace, if given before the name ('urn:example name').
+	Prefix    string   // The prefix, if the name is qualified by one ('atom:link').
+	Parents   []string // The names of the elements that enclose the element ('a>b>c').
+	Attribute bool
+	CharData  bool
+	InnerXml  bool
+	Ignore    bool
+}
+
+/*
+Parses the 'xml' key of a struct tag (the raw literal, with its quotes). False
+is returned if there is no such key.
+*/
+func parseXmlTag(literal string) (XmlTag, bool) {
+
+	var xmlTag XmlTag
+
+	tag, err := strconv.Unquote(literal)
+	if err != nil {
+		return xmlTag, false
+	}
+
+	value, ok := reflect.StructTag(tag).Lookup("xml")
+	if !ok {
+		return xmlTag, false
+	}
+
+	if value == "-" {
+		xmlTag.Ignore = true
+		return xmlTag, true
+	}
+
+	words := strings.Split(value, ",")
+	name := words[0]
+
+	if i := strings.Index(name, " "); i > -1 {
+		xmlTag.Namespace = name[:i]
+		name = name[i+1:]
+	}
+
+	if path := strings.Split(name, ">"); len(path) > 1 {
+		xmlTag.Parents = path[:len(path)-1]
+		name = path[len(path)-1]
+	}
+
+	if i := strings.Index(name, ":"); i > -1 {
+		xmlTag.Prefix = name[:i]
+		name = name[i+1:]
+	}
+
+	xmlTag.Name = name
+
+	for _, word := range words[1:] {
+		switch word {
+		case "attr":
+			xmlTag.Attribute = true
+		case "chardata":
+			xmlTag.CharData = true
+		case "innerxml":
+			xmlTag.InnerXml = true
+		}
+	}
+
+	return xmlTag, true
+}
+
+// Returns true if the type is encoding/xml's Name, which names the root element
+// of a type when it's the type of its XMLName field.
+func isXmlName(t types.Type) bool {
+	obj := namedObject(t)
+	return obj != nil && obj.Pkg() != nil && obj.Pkg().Path() == "encoding/xml" && obj.Name() == "Name"
+}
+
+// Describes an element or attribute named by the tag. Nil is returned if there's
+// nothing to describe.
+func (this XmlTag) XMLObject() *spec.XMLObject {
+
+	if this.Name == "" && this.Namespace == "" && !this.Attribute {
+		return nil
+	}
+
+	return &spec.XMLObject{
+		Name:      this.Name,
+		Namespace: this.Namespace,
+		Prefix:    this.Prefix,
+		Attribute: this.Attribute,
+	}
+}
+
+/*
+Adds the XML metadata of a member to its schema. The elements of a slice are
+named after the member, either directly enclosed by the parent element, or, when
+a path is given ('emails>email'), wrapped by an element of their own.
+*/
+func (this XmlTag) apply(schema *spec.Schema) {
+
+	switch {
+	case this.Ignore:
+		return
+	case this.CharData:
+		schema.AddExtension("x-xml-chardata", true)
+		return
+	case this.InnerXml:
+		schema.AddExtension("x-xml-innerxml", true)
+		return
+	}
+
+	if schema.Items == nil || schema.Items.Schema == nil || this.Attribute {
+		schema.XML = this.XMLObject()
+		return
+	}
+
+	if len(this.Parents) > 0 {
+		schema.XML = &spec.XMLObject{
+			Name:      this.Parents[len(this.Parents)-1],
+			Namespace: this.Namespace,
+			Prefix:    this.Prefix,
+			Wrapped:   true,
+		}
+	}
+
+	schema.Items.Schema.XML = this.XMLObject()
+}